@@ -0,0 +1,123 @@
+package aws
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	// TieBreakerAttachedToMe prefers the match attached to this instance.
+	TieBreakerAttachedToMe = "attached-to-me"
+	// TieBreakerNewest prefers the match created last, volumes only.
+	TieBreakerNewest = "newest"
+	// TieBreakerOldest prefers the match created first, volumes only.
+	TieBreakerOldest = "oldest"
+	// TieBreakerSameAZ prefers matches in the availability zone of this
+	// instance.
+	TieBreakerSameAZ = "same-az"
+)
+
+// match is a volume or ENI matching the filters, it carries everything
+// reported when the filters are ambiguous.
+type match struct {
+	attachedTo       []string
+	availabilityZone string
+	createTime       time.Time
+	id               string
+	state            string
+}
+
+func (m match) String() string {
+	created := "-"
+	if !m.createTime.IsZero() {
+		created = m.createTime.UTC().Format(time.RFC3339)
+	}
+	return fmt.Sprintf("id=%s state=%s az=%s created=%s attached-to=%v", m.id, m.state, m.availabilityZone, created, m.attachedTo)
+}
+
+func validateTieBreakers(tieBreakers []string, createTime bool) error {
+	for _, t := range tieBreakers {
+		switch t {
+		case TieBreakerAttachedToMe, TieBreakerSameAZ:
+		case TieBreakerNewest, TieBreakerOldest:
+			if !createTime {
+				return microerror.Maskf(invalidConfigError, "tie-breaker %q is not supported, the resource has no creation time", t)
+			}
+		default:
+			return microerror.Maskf(invalidConfigError, "unknown tie-breaker %q", t)
+		}
+	}
+	return nil
+}
+
+// breakTie reports all matches and applies the tie-breakers in the given
+// order until one match is left. Without tie-breakers, or if they do not
+// resolve the ambiguity, ambiguousMatchError is returned.
+func breakTie(ec2Client ec2iface.EC2API, instanceID string, kind string, matches []match, tieBreakers []string) (string, error) {
+	fmt.Printf("Filters matched %d %ss:\n", len(matches), kind)
+	for _, m := range matches {
+		fmt.Printf("  %s\n", m)
+	}
+
+	candidates := matches
+	for _, t := range tieBreakers {
+		if len(candidates) == 1 {
+			break
+		}
+
+		var preferred []match
+		switch t {
+		case TieBreakerAttachedToMe:
+			for _, m := range candidates {
+				for _, i := range m.attachedTo {
+					if i == instanceID {
+						preferred = append(preferred, m)
+						break
+					}
+				}
+			}
+		case TieBreakerNewest, TieBreakerOldest:
+			sorted := append([]match(nil), candidates...)
+			sort.SliceStable(sorted, func(i, j int) bool {
+				return sorted[i].createTime.Before(sorted[j].createTime)
+			})
+			pick := sorted[0]
+			if t == TieBreakerNewest {
+				pick = sorted[len(sorted)-1]
+			}
+			for _, m := range sorted {
+				if m.createTime.Equal(pick.createTime) {
+					preferred = append(preferred, m)
+				}
+			}
+		case TieBreakerSameAZ:
+			instance, err := describeInstance(ec2Client, instanceID)
+			if err != nil {
+				return "", microerror.Mask(err)
+			}
+			for _, m := range candidates {
+				if m.availabilityZone == aws.StringValue(instance.Placement.AvailabilityZone) {
+					preferred = append(preferred, m)
+				}
+			}
+		}
+
+		// a tie-breaker which matches nothing expresses no preference
+		if len(preferred) > 0 {
+			candidates = preferred
+		}
+		fmt.Printf("Tie-breaker %q left %d %ss.\n", t, len(candidates), kind)
+	}
+
+	if len(candidates) != 1 {
+		return "", microerror.Maskf(ambiguousMatchError, "expected 1 %s but filters matched %d, see the list above or set tie-breakers", kind, len(matches))
+	}
+
+	fmt.Printf("Selected %s %q.\n", kind, candidates[0].id)
+	return candidates[0].id, nil
+}
@@ -0,0 +1,197 @@
+package aws
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+	"github.com/giantswarm/backoff"
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	// wait for the change to be propagated for 10 mins
+	dnsSyncMaxRetries    = 60
+	dnsSyncRetryInterval = time.Second * 10
+)
+
+type DNSConfig struct {
+	AwsSession   *session.Session
+	HostedZoneID string
+	// Route53Client is optional and created from AwsSession if nil, it allows
+	// to use a local stand-in for Route53.
+	Route53Client route53iface.Route53API
+	RecordName    string
+	TTL           int64
+}
+
+type DNS struct {
+	hostedZoneID      string
+	recordName        string
+	route53Client     route53iface.Route53API
+	syncRetryInterval time.Duration
+	ttl               int64
+}
+
+func NewDNS(config DNSConfig) (*DNS, error) {
+	if config.AwsSession == nil && config.Route53Client == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.AwsSession or config.Route53Client must not be nil")
+	}
+	if config.HostedZoneID == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.HostedZoneID must not be empty")
+	}
+	if config.RecordName == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.RecordName must not be empty")
+	}
+	if config.TTL <= 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.TTL must be greater than 0")
+	}
+
+	route53Client := config.Route53Client
+	if route53Client == nil {
+		route53Client = route53.New(config.AwsSession)
+	}
+
+	newDNS := &DNS{
+		hostedZoneID:      config.HostedZoneID,
+		recordName:        config.RecordName,
+		route53Client:     route53Client,
+		syncRetryInterval: dnsSyncRetryInterval,
+		ttl:               config.TTL,
+	}
+	return newDNS, nil
+}
+
+// UpsertRecords points the record to the given addresses, creating an A record
+// for IPv4 and an AAAA record for IPv6 addresses, and waits until the change
+// is in sync on all Route53 DNS servers. A record of a type without addresses
+// is deleted, e.g. the AAAA record once the ENI lost its IPv6 addresses, so
+// clients do not keep connecting to an address of another instance.
+func (d *DNS) UpsertRecords(addresses []string) error {
+	values := map[string][]*route53.ResourceRecord{}
+	for _, a := range addresses {
+		ip := net.ParseIP(a)
+		if ip == nil {
+			return microerror.Maskf(executionFailedError, "invalid address %q", a)
+		}
+		recordType := route53.RRTypeA
+		if ip.To4() == nil {
+			recordType = route53.RRTypeAaaa
+		}
+		values[recordType] = append(values[recordType], &route53.ResourceRecord{Value: aws.String(ip.String())})
+	}
+
+	var changes []*route53.Change
+	for _, recordType := range []string{route53.RRTypeA, route53.RRTypeAaaa} {
+		if len(values[recordType]) == 0 {
+			continue
+		}
+		changes = append(changes, &route53.Change{
+			Action: aws.String(route53.ChangeActionUpsert),
+			ResourceRecordSet: &route53.ResourceRecordSet{
+				Name:            aws.String(d.recordName),
+				ResourceRecords: values[recordType],
+				TTL:             aws.Int64(d.ttl),
+				Type:            aws.String(recordType),
+			},
+		})
+	}
+	if len(changes) == 0 {
+		return microerror.Maskf(executionFailedError, "no addresses given for record %#q", d.recordName)
+	}
+	for _, recordType := range []string{route53.RRTypeA, route53.RRTypeAaaa} {
+		if len(values[recordType]) > 0 {
+			continue
+		}
+		stale, err := d.findRecord(recordType)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		if stale == nil {
+			continue
+		}
+		fmt.Printf("Deleting stale %s record %q as no such addresses are given.\n", recordType, d.recordName)
+		changes = append(changes, &route53.Change{
+			Action:            aws.String(route53.ChangeActionDelete),
+			ResourceRecordSet: stale,
+		})
+	}
+
+	o, err := d.route53Client.ChangeResourceRecordSets(&route53.ChangeResourceRecordSetsInput{
+		ChangeBatch: &route53.ChangeBatch{
+			Changes: changes,
+			Comment: aws.String("aws-attach-etcd-dep"),
+		},
+		HostedZoneId: aws.String(d.hostedZoneID),
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	fmt.Printf("Upserted record %q to %s in hosted zone %q, change %q.\n", d.recordName, strings.Join(addresses, ","), d.hostedZoneID, *o.ChangeInfo.Id)
+
+	err = d.waitInSync(*o.ChangeInfo.Id)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// findRecord returns the record set of the given type, nil if it does not
+// exist. A delete must match the existing record set exactly.
+func (d *DNS) findRecord(recordType string) (*route53.ResourceRecordSet, error) {
+	o, err := d.route53Client.ListResourceRecordSets(&route53.ListResourceRecordSetsInput{
+		HostedZoneId:    aws.String(d.hostedZoneID),
+		MaxItems:        aws.String("1"),
+		StartRecordName: aws.String(d.recordName),
+		StartRecordType: aws.String(recordType),
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	for _, r := range o.ResourceRecordSets {
+		if normalizeRecordName(aws.StringValue(r.Name)) == normalizeRecordName(d.recordName) && aws.StringValue(r.Type) == recordType {
+			return r, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// normalizeRecordName makes record names comparable, Route53 returns them
+// lower case and fully qualified.
+func normalizeRecordName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
+
+func (d *DNS) waitInSync(changeID string) error {
+	b := backoff.NewMaxRetries(dnsSyncMaxRetries, d.syncRetryInterval)
+	o := func() error {
+		out, err := d.route53Client.GetChange(&route53.GetChangeInput{
+			Id: aws.String(changeID),
+		})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		if *out.ChangeInfo.Status != route53.ChangeStatusInsync {
+			fmt.Printf("Change state is %q, expecting %q, retrying in %ds.\n", *out.ChangeInfo.Status, route53.ChangeStatusInsync, d.syncRetryInterval/time.Second)
+			return microerror.Maskf(executionFailedError, "change not in sync")
+		}
+		return nil
+	}
+	err := backoff.Retry(o, b)
+	if err != nil {
+		fmt.Printf("Failed to wait for change %q after %d retries.\n", changeID, dnsSyncMaxRetries)
+		return microerror.Mask(err)
+	}
+
+	fmt.Printf("Change %q is %q.\n", changeID, route53.ChangeStatusInsync)
+	return nil
+}
@@ -0,0 +1,175 @@
+package aws
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+)
+
+type fakeRoute53 struct {
+	route53iface.Route53API
+
+	changes []*route53.Change
+	// records returned by ListResourceRecordSets
+	records []*route53.ResourceRecordSet
+	// statuses returned by consecutive GetChange calls
+	statuses       []string
+	getChangeCalls int
+}
+
+func (f *fakeRoute53) ChangeResourceRecordSets(input *route53.ChangeResourceRecordSetsInput) (*route53.ChangeResourceRecordSetsOutput, error) {
+	f.changes = append(f.changes, input.ChangeBatch.Changes...)
+	return &route53.ChangeResourceRecordSetsOutput{
+		ChangeInfo: &route53.ChangeInfo{
+			Id:     aws.String("/change/C1"),
+			Status: aws.String(route53.ChangeStatusPending),
+		},
+	}, nil
+}
+
+func (f *fakeRoute53) GetChange(input *route53.GetChangeInput) (*route53.GetChangeOutput, error) {
+	status := f.statuses[len(f.statuses)-1]
+	if f.getChangeCalls < len(f.statuses) {
+		status = f.statuses[f.getChangeCalls]
+	}
+	f.getChangeCalls++
+	return &route53.GetChangeOutput{
+		ChangeInfo: &route53.ChangeInfo{
+			Id:     input.Id,
+			Status: aws.String(status),
+		},
+	}, nil
+}
+
+func (f *fakeRoute53) ListResourceRecordSets(input *route53.ListResourceRecordSetsInput) (*route53.ListResourceRecordSetsOutput, error) {
+	// like Route53 the listing starts at the given name and type and may
+	// return the next record set if there is no exact match
+	for _, r := range f.records {
+		if aws.StringValue(r.Type) >= aws.StringValue(input.StartRecordType) {
+			return &route53.ListResourceRecordSetsOutput{ResourceRecordSets: []*route53.ResourceRecordSet{r}}, nil
+		}
+	}
+	return &route53.ListResourceRecordSetsOutput{}, nil
+}
+
+func recordSet(recordType string, values ...string) *route53.ResourceRecordSet {
+	r := &route53.ResourceRecordSet{
+		Name: aws.String("etcd1.example.com."),
+		TTL:  aws.Int64(60),
+		Type: aws.String(recordType),
+	}
+	for _, v := range values {
+		r.ResourceRecords = append(r.ResourceRecords, &route53.ResourceRecord{Value: aws.String(v)})
+	}
+	return r
+}
+
+func Test_DNS_UpsertRecords(t *testing.T) {
+	testCases := []struct {
+		name              string
+		addresses         []string
+		records           []*route53.ResourceRecordSet
+		statuses          []string
+		expectedChanges   map[string]*route53.ResourceRecordSet
+		expectedGetChange int
+		expectedErr       bool
+	}{
+		{
+			name:      "case 0: A record in sync right away",
+			addresses: []string{"10.0.1.10"},
+			statuses:  []string{route53.ChangeStatusInsync},
+			expectedChanges: map[string]*route53.ResourceRecordSet{
+				route53.ChangeActionUpsert + route53.RRTypeA: recordSet(route53.RRTypeA, "10.0.1.10"),
+			},
+			expectedGetChange: 1,
+		},
+		{
+			name:      "case 1: A and AAAA records wait for sync",
+			addresses: []string{"10.0.1.10", "2001:db8:1:2::10", "2001:db8:1:2::11"},
+			statuses:  []string{route53.ChangeStatusPending, route53.ChangeStatusPending, route53.ChangeStatusInsync},
+			expectedChanges: map[string]*route53.ResourceRecordSet{
+				route53.ChangeActionUpsert + route53.RRTypeA:    recordSet(route53.RRTypeA, "10.0.1.10"),
+				route53.ChangeActionUpsert + route53.RRTypeAaaa: recordSet(route53.RRTypeAaaa, "2001:db8:1:2::10", "2001:db8:1:2::11"),
+			},
+			expectedGetChange: 3,
+		},
+		{
+			name:      "case 2: stale AAAA record is deleted",
+			addresses: []string{"10.0.1.10"},
+			records:   []*route53.ResourceRecordSet{recordSet(route53.RRTypeA, "10.0.1.9"), recordSet(route53.RRTypeAaaa, "2001:db8:1:2::9")},
+			statuses:  []string{route53.ChangeStatusInsync},
+			expectedChanges: map[string]*route53.ResourceRecordSet{
+				route53.ChangeActionUpsert + route53.RRTypeA:    recordSet(route53.RRTypeA, "10.0.1.10"),
+				route53.ChangeActionDelete + route53.RRTypeAaaa: recordSet(route53.RRTypeAaaa, "2001:db8:1:2::9"),
+			},
+			expectedGetChange: 1,
+		},
+		{
+			name:      "case 3: record of another type following A is kept",
+			addresses: []string{"10.0.1.10"},
+			records:   []*route53.ResourceRecordSet{recordSet(route53.RRTypeTxt, "owner")},
+			statuses:  []string{route53.ChangeStatusInsync},
+			expectedChanges: map[string]*route53.ResourceRecordSet{
+				route53.ChangeActionUpsert + route53.RRTypeA: recordSet(route53.RRTypeA, "10.0.1.10"),
+			},
+			expectedGetChange: 1,
+		},
+		{
+			name:              "case 4: change never in sync",
+			addresses:         []string{"10.0.1.10"},
+			statuses:          []string{route53.ChangeStatusPending},
+			expectedGetChange: dnsSyncMaxRetries,
+			expectedErr:       true,
+		},
+		{
+			name:        "case 5: invalid address",
+			addresses:   []string{"10.0.1"},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := &fakeRoute53{
+				records:  tc.records,
+				statuses: tc.statuses,
+			}
+			d, err := NewDNS(DNSConfig{
+				HostedZoneID:  "Z1",
+				RecordName:    "etcd1.example.com.",
+				Route53Client: fake,
+				TTL:           60,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error %#v", err)
+			}
+			d.syncRetryInterval = 0
+
+			err = d.UpsertRecords(tc.addresses)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error %#v", err)
+			}
+
+			if fake.getChangeCalls != tc.expectedGetChange {
+				t.Fatalf("expected %d GetChange calls, got %d", tc.expectedGetChange, fake.getChangeCalls)
+			}
+			if tc.expectedChanges == nil {
+				return
+			}
+			changes := map[string]*route53.ResourceRecordSet{}
+			for _, c := range fake.changes {
+				changes[aws.StringValue(c.Action)+aws.StringValue(c.ResourceRecordSet.Type)] = c.ResourceRecordSet
+			}
+			if !reflect.DeepEqual(changes, tc.expectedChanges) {
+				t.Fatalf("expected changes %v, got %v", tc.expectedChanges, changes)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package aws
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/giantswarm/microerror"
+)
+
+// DecryptKMSTag decrypts the base64 encoded KMS ciphertext stored in the
+// given tag of the volume found by AttachByTag.
+func (s *EBS) DecryptKMSTag(key string) ([]byte, error) {
+	value, ok := s.Tag(key)
+	if !ok {
+		return nil, microerror.Maskf(executionFailedError, "volume has no tag %#q", key)
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, microerror.Maskf(executionFailedError, "tag %#q is not base64 encoded: %s", key, err)
+	}
+
+	kmsClient := kms.New(s.awsSession)
+	o, err := kmsClient.Decrypt(&kms.DecryptInput{
+		CiphertextBlob: ciphertext,
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	fmt.Printf("Decrypted encryption key from volume tag %q using KMS key %q.\n", key, aws.StringValue(o.KeyId))
+
+	return o.Plaintext, nil
+}
+
+// GetSSMParameter returns the decrypted value of the given SSM parameter.
+func GetSSMParameter(awsSession *session.Session, name string) ([]byte, error) {
+	ssmClient := ssm.New(awsSession)
+	o, err := ssmClient.GetParameter(&ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	fmt.Printf("Fetched encryption key from SSM parameter %q.\n", name)
+
+	return []byte(aws.StringValue(o.Parameter.Value)), nil
+}
@@ -6,8 +6,10 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/giantswarm/backoff"
 	"github.com/giantswarm/microerror"
 
@@ -17,19 +19,56 @@ import (
 type ENIConfig struct {
 	AWSInstanceID string
 	AwsSession    *session.Session
-	DeviceIndex   int64
-	ForceDetach   bool
-	TagKey        string
-	TagValue      string
+	// DeviceIndex of the ENI attachment, 0 selects the lowest free index.
+	DeviceIndex int64
+	// Filters are additional EC2 filters given as name=value, see
+	// buildFilters.
+	Filters     []string
+	ForceDetach bool
+	// NetworkCardIndex of the ENI attachment, -1 selects the lowest network
+	// card with free capacity.
+	NetworkCardIndex int64
+	// Pool allows the filters to match several ENIs, an available one in the
+	// availability zone of the instance is claimed by setting PoolClaimTagKey
+	// to the instance ID.
+	Pool            bool
+	PoolClaimTagKey string
+	// Routing holds the host specific routing settings, the ENI specific
+	// fields are filled in after the ENI was attached.
+	Routing routing.Config
+	// SecurityGroupIDs and SecurityGroupTags define the desired security
+	// groups of the ENI, tags are given as key=value. If both are empty the
+	// security groups are not managed.
+	SecurityGroupIDs  []string
+	SecurityGroupTags []string
+	// SourceDestCheck is the desired source/destination check of the ENI, nil
+	// leaves it unmanaged.
+	SourceDestCheck *bool
+	TagKey          string
+	TagValue        string
+	// TieBreakers select one of several ENIs matching the filters, they are
+	// applied in the given order. ENIs have no creation time, so only
+	// TieBreakerAttachedToMe and TieBreakerSameAZ are supported.
+	TieBreakers []string
 }
 
 type ENI struct {
-	awsInstanceID string
-	awsSession    *session.Session
-	deviceIndex   int64
-	forceDetach   bool
-	tagKey        string
-	tagValue      string
+	awsInstanceID     string
+	awsSession        *session.Session
+	deviceIndex       int64
+	filters           []*ec2.Filter
+	forceDetach       bool
+	networkCardIndex  int64
+	pool              bool
+	poolClaimTagKey   string
+	routing           routing.Config
+	securityGroupIDs  []string
+	securityGroupTags []string
+	sourceDestCheck   *bool
+	tieBreakers       []string
+
+	eni           *ec2.NetworkInterface
+	interfaceName string
 }
 
 func NewENI(config ENIConfig) (*ENI, error) {
@@ -39,57 +78,117 @@ func NewENI(config ENIConfig) (*ENI, error) {
 	if config.AwsSession == nil {
 		return nil, microerror.Maskf(invalidConfigError, "config.AwsSession must not be nil")
 	}
-	if config.DeviceIndex == 0 {
-		return nil, microerror.Maskf(invalidConfigError, "config.DeviceIndex must not be 0")
+	if config.DeviceIndex < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.DeviceIndex must not be negative")
 	}
-	if config.TagKey == "" {
-		return nil, microerror.Maskf(invalidConfigError, "config.TagKey must not be empty")
+	if config.NetworkCardIndex < -1 {
+		return nil, microerror.Maskf(invalidConfigError, "config.NetworkCardIndex must be -1 or greater")
 	}
-	if config.TagValue == "" {
-		return nil, microerror.Maskf(invalidConfigError, "config.TagValue must not be empty")
+	if config.Routing.Backend == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.Routing.Backend must not be empty")
+	}
+	if config.Pool && config.PoolClaimTagKey == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.PoolClaimTagKey must not be empty in pool mode")
+	}
+	filters, err := buildFilters(config.TagKey, config.TagValue, config.Filters)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	err = validateTieBreakers(config.TieBreakers, false)
+	if err != nil {
+		return nil, microerror.Mask(err)
 	}
 
 	newENI := &ENI{
-		awsInstanceID: config.AWSInstanceID,
-		awsSession:    config.AwsSession,
-		deviceIndex:   config.DeviceIndex,
-		forceDetach:   config.ForceDetach,
-		tagKey:        config.TagKey,
-		tagValue:      config.TagValue,
+		awsInstanceID:     config.AWSInstanceID,
+		awsSession:        config.AwsSession,
+		deviceIndex:       config.DeviceIndex,
+		filters:           filters,
+		forceDetach:       config.ForceDetach,
+		networkCardIndex:  config.NetworkCardIndex,
+		pool:              config.Pool,
+		poolClaimTagKey:   config.PoolClaimTagKey,
+		routing:           config.Routing,
+		securityGroupIDs:  config.SecurityGroupIDs,
+		securityGroupTags: config.SecurityGroupTags,
+		sourceDestCheck:   config.SourceDestCheck,
+		tieBreakers:       config.TieBreakers,
 	}
 	return newENI, nil
 }
 
 func (s *ENI) AttachByTag() error {
+	var detachMode string
+	var err error
+
 	// create ec2 client
 	ec2Client := ec2.New(s.awsSession)
 
-	eni, err := s.describe(ec2Client)
-	if err != nil {
-		return microerror.Mask(err)
-	}
-	fmt.Printf("Fetched eni-id '%s'\n", *eni.NetworkInterfaceId)
-
-	if *eni.Status == ec2.NetworkInterfaceStatusInUse &&
-		*eni.Attachment.InstanceId == s.awsInstanceID {
-		fmt.Printf("ENI is already attached to this instance. Nothing to do.\n")
-		return nil
-	} else if *eni.Status == ec2.NetworkInterfaceStatusInUse {
-		fmt.Printf("ENI is attached to %q and is in state %q. Trying detach the volume\n", *eni.Attachment.InstanceId, *eni.Status)
+	var eni *ec2.NetworkInterface
+	var attached bool
+	if s.pool {
+		eni, attached, err = s.attachFromPool(ec2Client)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		s.eni = eni
 
-		err := s.detach(ec2Client, eni)
+		err = s.reconcileAttributes(ec2Client, eni)
 		if err != nil {
 			return microerror.Mask(err)
 		}
+
+		if !attached {
+			fmt.Printf("ENI is already attached to this instance.\n")
+		}
 	} else {
-		fmt.Printf("ENI state is %q.\n", *eni.Status)
+		eni, err = s.describe(ec2Client, maxRetries, retryInterval)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		fmt.Printf("Fetched eni-id '%s'\n", *eni.NetworkInterfaceId)
+		s.eni = eni
+
+		err = s.reconcileAttributes(ec2Client, eni)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		if *eni.Status == ec2.NetworkInterfaceStatusInUse &&
+			*eni.Attachment.InstanceId == s.awsInstanceID {
+			fmt.Printf("ENI is already attached to this instance.\n")
+		} else {
+			if *eni.Status == ec2.NetworkInterfaceStatusInUse {
+				fmt.Printf("ENI is attached to %q and is in state %q. Trying detach the volume\n", *eni.Attachment.InstanceId, *eni.Status)
+
+				detachMode, err = s.detach(ec2Client, eni)
+				if err != nil {
+					return microerror.Mask(err)
+				}
+			} else {
+				fmt.Printf("ENI state is %q.\n", *eni.Status)
+			}
+
+			err = s.attach(ec2Client, s.awsInstanceID, *eni.NetworkInterfaceId)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+			attached = true
+		}
 	}
 
-	err = s.attach(ec2Client, s.awsInstanceID, *eni.NetworkInterfaceId)
-	if err != nil {
-		return microerror.Mask(err)
+	// the history is for auditing only, failing to record it must not stop
+	// the eni from being used
+	if attached {
+		err = recordAttachment(ec2Client, *eni.NetworkInterfaceId, eni.TagSet, s.awsInstanceID, detachMode)
+		if err != nil {
+			fmt.Printf("Failed to record attachment history of eni %q, err: %s.\n", *eni.NetworkInterfaceId, err)
+		}
 	}
 
+	// the routing is configured even if the eni was attached already, so it
+	// is re-applied after a reboot and picks up new addresses of the eni
+
 	awsEniSubnet, err := s.describeSubnet(ec2Client, *eni.SubnetId)
 	if err != nil {
 		return microerror.Mask(err)
@@ -100,63 +199,346 @@ func (s *ENI) AttachByTag() error {
 		return microerror.Mask(err)
 	}
 
-	err = routing.ConfigureNetworkRoutingForENI(*eni.PrivateIpAddress, ipNet)
+	interfaceName, err := s.InterfaceName()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	var secondaryAddresses []string
+	for _, a := range eni.PrivateIpAddresses {
+		if aws.BoolValue(a.Primary) {
+			continue
+		}
+		secondaryAddresses = append(secondaryAddresses, *a.PrivateIpAddress)
+	}
+	var ipv4Prefixes []string
+	for _, p := range eni.Ipv4Prefixes {
+		ipv4Prefixes = append(ipv4Prefixes, *p.Ipv4Prefix)
+	}
+
+	var ipv6Addresses []string
+	for _, a := range eni.Ipv6Addresses {
+		ipv6Addresses = append(ipv6Addresses, *a.Ipv6Address)
+	}
+	var ipv6Prefixes []string
+	for _, p := range eni.Ipv6Prefixes {
+		ipv6Prefixes = append(ipv6Prefixes, *p.Ipv6Prefix)
+	}
+
+	var ipv6Net *net.IPNet
+	if len(ipv6Addresses) > 0 || len(ipv6Prefixes) > 0 {
+		ipv6Net, err = subnetIPv6CIDR(awsEniSubnet)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	routingConfig := s.routing
+	routingConfig.ENIAddress = *eni.PrivateIpAddress
+	routingConfig.ENIIPv4Prefixes = ipv4Prefixes
+	routingConfig.ENIIPv6Addresses = ipv6Addresses
+	routingConfig.ENIIPv6Prefixes = ipv6Prefixes
+	routingConfig.ENIIPv6Subnet = ipv6Net
+	routingConfig.ENISecondaryAddresses = secondaryAddresses
+	routingConfig.ENISubnet = ipNet
+	routingConfig.InterfaceName = interfaceName
+	routingConfig.MACAddress = *eni.MacAddress
+
+	err = routing.ConfigureNetworkRoutingForENI(routingConfig)
 	if err != nil {
 		return microerror.Mask(err)
 	}
-	fmt.Printf("Sucesfully configured routing for eth1  for ip %s.\n", *eni.PrivateIpAddress)
+	fmt.Printf("Sucesfully configured routing for %s for ip %s.\n", interfaceName, *eni.PrivateIpAddress)
+	if len(secondaryAddresses) > 0 || len(ipv4Prefixes) > 0 {
+		fmt.Printf("Sucesfully configured routing for %s for secondary ips %s and prefixes %s.\n", interfaceName, secondaryAddresses, ipv4Prefixes)
+	}
+	if len(ipv6Addresses) > 0 || len(ipv6Prefixes) > 0 {
+		fmt.Printf("Sucesfully configured routing for %s for ipv6 %s and prefixes %s.\n", interfaceName, ipv6Addresses, ipv6Prefixes)
+	}
 	return nil
 }
 
-func (s *ENI) describe(ec2Client *ec2.EC2) (*ec2.NetworkInterface, error) {
-	eniFilter := &ec2.Filter{
-		Name:   tagKey(s.tagKey),
-		Values: tagValue(s.tagValue),
+// ID returns the ID of the ENI found by AttachByTag.
+func (s *ENI) ID() string {
+	if s.eni == nil {
+		return ""
+	}
+	return *s.eni.NetworkInterfaceId
+}
+
+// InterfaceName returns the name of the interface of the ENI found by
+// AttachByTag, waiting until the kernel registered it.
+func (s *ENI) InterfaceName() (string, error) {
+	if s.eni == nil {
+		return "", microerror.Maskf(executionFailedError, "eni was not attached yet")
 	}
+	if s.interfaceName != "" {
+		return s.interfaceName, nil
+	}
+
+	interfaceName, err := routing.WaitForInterface(*s.eni.MacAddress)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+	s.interfaceName = interfaceName
+
+	return interfaceName, nil
+}
+
+// Addresses returns the primary private IPv4 address and the IPv6 addresses
+// of the ENI found by AttachByTag.
+func (s *ENI) Addresses() []string {
+	if s.eni == nil {
+		return nil
+	}
+	addresses := []string{*s.eni.PrivateIpAddress}
+	for _, a := range s.eni.Ipv6Addresses {
+		addresses = append(addresses, *a.Ipv6Address)
+	}
+	return addresses
+}
 
-	describeVolumeInput := &ec2.DescribeNetworkInterfacesInput{
-		Filters: []*ec2.Filter{
-			eniFilter,
-		},
+// AllAddresses returns the primary and secondary private IPv4 addresses, the
+// IPv6 addresses and the delegated IPv4 and IPv6 prefixes in CIDR notation of
+// the ENI found by AttachByTag.
+func (s *ENI) AllAddresses() []string {
+	if s.eni == nil {
+		return nil
+	}
+	addresses := []string{*s.eni.PrivateIpAddress}
+	for _, a := range s.eni.PrivateIpAddresses {
+		if aws.BoolValue(a.Primary) {
+			continue
+		}
+		addresses = append(addresses, *a.PrivateIpAddress)
 	}
+	for _, p := range s.eni.Ipv4Prefixes {
+		addresses = append(addresses, *p.Ipv4Prefix)
+	}
+	for _, a := range s.eni.Ipv6Addresses {
+		addresses = append(addresses, *a.Ipv6Address)
+	}
+	for _, p := range s.eni.Ipv6Prefixes {
+		addresses = append(addresses, *p.Ipv6Prefix)
+	}
+	return addresses
+}
+
+// describe finds the ENI matching the filters, retrying up to retries times
+// while it is missing, e.g. because it is not created yet.
+func (s *ENI) describe(ec2Client ec2iface.EC2API, retries uint64, interval time.Duration) (*ec2.NetworkInterface, error) {
 	var eni *ec2.NetworkInterface
-	b := backoff.NewMaxRetries(maxRetries, retryInterval)
+	b := backoff.NewMaxRetries(retries, interval)
 	o := func() error {
-		out, err := ec2Client.DescribeNetworkInterfaces(describeVolumeInput)
+		enis, err := s.describeNetworkInterfaces(ec2Client, s.filters)
 		if err != nil {
 			return microerror.Mask(err)
 		}
 
-		// tags should give us only one unique volume
-		if len(out.NetworkInterfaces) != 1 {
-			fmt.Printf("expected 1 eni but got %d instead retrying in %ds\n", len(out.NetworkInterfaces), retryInterval/time.Second)
-			return microerror.Maskf(executionFailedError, "expected 1 eni but got %d instead", len(out.NetworkInterfaces))
+		// in pool mode the eni of this instance is the one attached to or
+		// claimed by it
+		if s.pool {
+			id, ok := s.newPool(ec2Client).mine(eniCandidates(enis, s.poolClaimTagKey))
+			if !ok {
+				fmt.Printf("no eni matching %s is attached to or claimed by this instance retrying in %ds\n", filtersString(s.filters), interval/time.Second)
+				return microerror.Maskf(executionFailedError, "no eni matching %s is attached to or claimed by this instance", filtersString(s.filters))
+			}
+			for _, e := range enis {
+				if *e.NetworkInterfaceId == id {
+					eni = e
+				}
+			}
+			return nil
+		}
+
+		// the eni might not be created yet
+		if len(enis) == 0 {
+			fmt.Printf("expected 1 eni matching %s but got none retrying in %ds\n", filtersString(s.filters), interval/time.Second)
+			return microerror.Maskf(executionFailedError, "expected 1 eni matching %s but got none", filtersString(s.filters))
+		}
+		// tags should give us only one unique eni
+		if len(enis) == 1 {
+			eni = enis[0]
+			return nil
 		}
 
-		eni = out.NetworkInterfaces[0]
+		var matches []match
+		for _, e := range enis {
+			m := match{
+				availabilityZone: aws.StringValue(e.AvailabilityZone),
+				id:               aws.StringValue(e.NetworkInterfaceId),
+				state:            aws.StringValue(e.Status),
+			}
+			if e.Attachment != nil {
+				m.attachedTo = append(m.attachedTo, aws.StringValue(e.Attachment.InstanceId))
+			}
+			matches = append(matches, m)
+		}
+		id, err := breakTie(ec2Client, s.awsInstanceID, "eni", matches, s.tieBreakers)
+		if IsAmbiguousMatch(err) {
+			// retrying does not resolve an ambiguous match
+			return backoff.Permanent(err)
+		} else if err != nil {
+			return microerror.Mask(err)
+		}
+		for _, e := range enis {
+			if *e.NetworkInterfaceId == id {
+				eni = e
+			}
+		}
 		return nil
 	}
 	err := backoff.Retry(o, b)
 	if err != nil {
-		fmt.Printf("Failed to describe eni after %d retries.\n", maxRetries)
+		fmt.Printf("Failed to describe eni after %d retries.\n", retries)
 		return nil, microerror.Mask(err)
 	}
 
 	return eni, nil
 }
 
-func (s *ENI) attach(ec2Client *ec2.EC2, instanceID string, eniID string) error {
+// attachFromPool claims an ENI of the pool and attaches it, retrying until
+// one is free, e.g. when the instance it was attached to is replaced. An ENI
+// of the pool is never detached from another instance, if another instance
+// attached the claimed ENI first the claim is released and the next free ENI
+// is claimed. It returns false if the ENI was already attached to this
+// instance.
+func (s *ENI) attachFromPool(ec2Client ec2iface.EC2API) (*ec2.NetworkInterface, bool, error) {
+	p := s.newPool(ec2Client)
+
+	var eni *ec2.NetworkInterface
+	var attached bool
+	b := backoff.NewMaxRetries(maxRetries, retryInterval)
+	o := func() error {
+		enis, err := s.describeNetworkInterfaces(ec2Client, s.filters)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		id, err := p.claim(eniCandidates(enis, s.poolClaimTagKey))
+		if err != nil {
+			fmt.Printf("Failed to claim eni from pool, retrying in %ds, err: %s.\n", retryInterval/time.Second, err)
+			return microerror.Mask(err)
+		}
+
+		enis, err = s.describeNetworkInterfaces(ec2Client, []*ec2.Filter{
+			{
+				Name:   aws.String("network-interface-id"),
+				Values: []*string{aws.String(id)},
+			},
+		})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		if len(enis) != 1 {
+			return microerror.Maskf(executionFailedError, "expected 1 eni for network-interface-id %#q", id)
+		}
+		eni = enis[0]
+		fmt.Printf("Fetched eni-id '%s'\n", id)
+
+		if eni.Attachment != nil && *eni.Attachment.InstanceId == s.awsInstanceID {
+			return nil
+		}
+
+		if *eni.Status == ec2.NetworkInterfaceStatusAvailable {
+			err = s.attach(ec2Client, s.awsInstanceID, id)
+		} else {
+			err = microerror.Maskf(resourceInUseError, "eni %q is in state %q", id, *eni.Status)
+		}
+		if IsResourceInUse(err) {
+			fmt.Printf("ENI %q was attached by another instance first, retrying in %ds.\n", id, retryInterval/time.Second)
+			releaseErr := p.release(id)
+			if releaseErr != nil {
+				return microerror.Mask(releaseErr)
+			}
+			return microerror.Mask(err)
+		} else if err != nil {
+			return backoff.Permanent(microerror.Mask(err))
+		}
+
+		attached = true
+		return nil
+	}
+	err := backoff.Retry(o, b)
+	if err != nil {
+		fmt.Printf("Failed to attach eni from pool after %d retries.\n", maxRetries)
+		return nil, false, microerror.Mask(err)
+	}
+
+	return eni, attached, nil
+}
+
+func (s *ENI) describeNetworkInterfaces(ec2Client ec2iface.EC2API, filters []*ec2.Filter) ([]*ec2.NetworkInterface, error) {
+	describeNetworkInterfacesInput := &ec2.DescribeNetworkInterfacesInput{
+		Filters: filters,
+	}
+	var enis []*ec2.NetworkInterface
+	err := ec2Client.DescribeNetworkInterfacesPages(describeNetworkInterfacesInput, func(o *ec2.DescribeNetworkInterfacesOutput, lastPage bool) bool {
+		enis = append(enis, o.NetworkInterfaces...)
+		return true
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return enis, nil
+}
+
+func (s *ENI) newPool(ec2Client ec2iface.EC2API) pool {
+	return pool{
+		availableState: ec2.NetworkInterfaceStatusAvailable,
+		claimTagKey:    s.poolClaimTagKey,
+		ec2Client:      ec2Client,
+		instanceID:     s.awsInstanceID,
+	}
+}
+
+func eniCandidates(enis []*ec2.NetworkInterface, claimTagKey string) []poolCandidate {
+	var candidates []poolCandidate
+	for _, e := range enis {
+		c := poolCandidate{
+			availabilityZone: aws.StringValue(e.AvailabilityZone),
+			claimedBy:        tagValueOf(e.TagSet, claimTagKey),
+			id:               aws.StringValue(e.NetworkInterfaceId),
+			state:            aws.StringValue(e.Status),
+		}
+		if e.Attachment != nil {
+			c.attachedTo = append(c.attachedTo, aws.StringValue(e.Attachment.InstanceId))
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates
+}
+
+func (s *ENI) attach(ec2Client ec2iface.EC2API, instanceID string, eniID string) error {
 	attachNetworkInterfaceInput := &ec2.AttachNetworkInterfaceInput{
 		DeviceIndex:        aws.Int64(s.deviceIndex),
 		InstanceId:         aws.String(instanceID),
 		NetworkInterfaceId: aws.String(eniID),
 	}
+	if s.networkCardIndex >= 0 {
+		attachNetworkInterfaceInput.NetworkCardIndex = aws.Int64(s.networkCardIndex)
+	}
 
 	b := backoff.NewMaxRetries(maxRetries, retryInterval)
 	o := func() error {
+		// the free slot is looked up on every attempt as a failed attempt may
+		// be caused by another interface being attached in the meantime
+		if s.deviceIndex == 0 {
+			free, err := freeSlot(ec2Client, instanceID, s.networkCardIndex)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+			attachNetworkInterfaceInput.DeviceIndex = aws.Int64(free.deviceIndex)
+			attachNetworkInterfaceInput.NetworkCardIndex = aws.Int64(free.networkCardIndex)
+		}
+
 		fmt.Printf("Attempting to attach ENI.\n")
 		attachment, err := ec2Client.AttachNetworkInterface(attachNetworkInterfaceInput)
-		if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "InvalidNetworkInterface.InUse" {
+			// another instance got the eni, retrying does not help
+			return backoff.Permanent(microerror.Maskf(resourceInUseError, "%s", aerr.Message()))
+		} else if err != nil {
 			fmt.Printf("Error attachine ENI: %s\n", err)
 			return microerror.Mask(err)
 		}
@@ -173,7 +555,7 @@ func (s *ENI) attach(ec2Client *ec2.EC2, instanceID string, eniID string) error
 
 	o = func() error {
 		fmt.Printf("Checking ENI ettachment was successful.\n")
-		eni, err := s.describe(ec2Client)
+		eni, err := s.describe(ec2Client, maxRetries, retryInterval)
 		if err != nil {
 			return microerror.Mask(err)
 		}
@@ -194,12 +576,12 @@ func (s *ENI) attach(ec2Client *ec2.EC2, instanceID string, eniID string) error
 	return nil
 }
 
-func (s *ENI) detach(ec2Client *ec2.EC2, eni *ec2.NetworkInterface) error {
+func (s *ENI) detach(ec2Client ec2iface.EC2API, eni *ec2.NetworkInterface) (string, error) {
 	// wait if automatic detach happens  by terminating the instance
 
 	b := backoff.NewMaxRetries(waitAutoDetachMaxRetries, retryInterval)
 	o := func() error {
-		eni, err := s.describe(ec2Client)
+		eni, err := s.describe(ec2Client, maxRetries, retryInterval)
 		if err != nil {
 			return microerror.Mask(err)
 		}
@@ -214,7 +596,7 @@ func (s *ENI) detach(ec2Client *ec2.EC2, eni *ec2.NetworkInterface) error {
 	err := backoff.Retry(o, b)
 	if err == nil {
 		// the ENI was eventually detached by the instance by itself, no need for manual detach
-		return nil
+		return detachModeAuto, nil
 	} else {
 		// eni is still attached after 10mins, lets try detach it manually here
 		detachNetworkInterfaceInput := &ec2.DetachNetworkInterfaceInput{
@@ -224,7 +606,7 @@ func (s *ENI) detach(ec2Client *ec2.EC2, eni *ec2.NetworkInterface) error {
 
 		detachment, err := ec2Client.DetachNetworkInterface(detachNetworkInterfaceInput)
 		if err != nil {
-			return microerror.Mask(err)
+			return "", microerror.Mask(err)
 		}
 		fmt.Printf("Succefully created dettach request. %s\n", detachment.String())
 
@@ -232,14 +614,18 @@ func (s *ENI) detach(ec2Client *ec2.EC2, eni *ec2.NetworkInterface) error {
 		err = backoff.Retry(o, b)
 		if err != nil {
 			fmt.Printf("Failed to detach eni after %d retries.\n", maxRetries)
-			return microerror.Mask(err)
+			return "", microerror.Mask(err)
 		}
 	}
-	fmt.Printf("ENI detached, state %q .\n", ec2.NetworkInterfaceStatusAvailable)
-	return nil
+	mode := detachModeManual
+	if s.forceDetach {
+		mode = detachModeForce
+	}
+	fmt.Printf("ENI detached with mode %q, state %q .\n", mode, ec2.NetworkInterfaceStatusAvailable)
+	return mode, nil
 }
 
-func (s *ENI) describeSubnet(ec2Client *ec2.EC2, subnetID string) (*ec2.Subnet, error) {
+func (s *ENI) describeSubnet(ec2Client ec2iface.EC2API, subnetID string) (*ec2.Subnet, error) {
 	describeSubnetInput := &ec2.DescribeSubnetsInput{
 		SubnetIds: []*string{aws.String(subnetID)},
 	}
@@ -255,3 +641,19 @@ func (s *ENI) describeSubnet(ec2Client *ec2.EC2, subnetID string) (*ec2.Subnet,
 
 	return o.Subnets[0], nil
 }
+
+// subnetIPv6CIDR returns the IPv6 CIDR associated with the subnet.
+func subnetIPv6CIDR(subnet *ec2.Subnet) (*net.IPNet, error) {
+	for _, a := range subnet.Ipv6CidrBlockAssociationSet {
+		if a.Ipv6CidrBlockState == nil || aws.StringValue(a.Ipv6CidrBlockState.State) != ec2.SubnetCidrBlockStateCodeAssociated {
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(aws.StringValue(a.Ipv6CidrBlock))
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		return ipNet, nil
+	}
+
+	return nil, microerror.Maskf(executionFailedError, "subnet %#q has no associated IPv6 CIDR block", aws.StringValue(subnet.SubnetId))
+}
@@ -0,0 +1,150 @@
+package aws
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/giantswarm/microerror"
+)
+
+// reconcileAttributes compares the security groups and the source/destination
+// check of the ENI with the desired state and modifies the ENI on drift.
+func (s *ENI) reconcileAttributes(ec2Client ec2iface.EC2API, eni *ec2.NetworkInterface) error {
+	drift, desiredGroups, err := s.attributeDrift(ec2Client, eni)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if len(drift) == 0 {
+		return nil
+	}
+
+	for _, d := range drift {
+		fmt.Printf("ENI %q drifted from desired state: %s.\n", *eni.NetworkInterfaceId, d)
+	}
+
+	if desiredGroups != nil && !equalStrings(eniGroupIDs(eni), desiredGroups) {
+		_, err = ec2Client.ModifyNetworkInterfaceAttribute(&ec2.ModifyNetworkInterfaceAttributeInput{
+			Groups:             aws.StringSlice(desiredGroups),
+			NetworkInterfaceId: eni.NetworkInterfaceId,
+		})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		fmt.Printf("Set security groups of ENI %q to %s.\n", *eni.NetworkInterfaceId, desiredGroups)
+	}
+
+	if s.sourceDestCheck != nil && aws.BoolValue(eni.SourceDestCheck) != *s.sourceDestCheck {
+		_, err = ec2Client.ModifyNetworkInterfaceAttribute(&ec2.ModifyNetworkInterfaceAttributeInput{
+			NetworkInterfaceId: eni.NetworkInterfaceId,
+			SourceDestCheck:    &ec2.AttributeBooleanValue{Value: s.sourceDestCheck},
+		})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		fmt.Printf("Set source/destination check of ENI %q to %t.\n", *eni.NetworkInterfaceId, *s.sourceDestCheck)
+	}
+
+	return nil
+}
+
+// attributeDrift returns a description of every attribute of the ENI that
+// differs from the desired state together with the desired security groups,
+// which are nil if security groups are not managed.
+func (s *ENI) attributeDrift(ec2Client ec2iface.EC2API, eni *ec2.NetworkInterface) ([]string, []string, error) {
+	var drift []string
+
+	desiredGroups, err := s.desiredSecurityGroups(ec2Client, eni)
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+	if desiredGroups != nil {
+		current := eniGroupIDs(eni)
+		if !equalStrings(current, desiredGroups) {
+			drift = append(drift, fmt.Sprintf("security groups are %s, desired %s", current, desiredGroups))
+		}
+	}
+
+	if s.sourceDestCheck != nil && aws.BoolValue(eni.SourceDestCheck) != *s.sourceDestCheck {
+		drift = append(drift, fmt.Sprintf("source/destination check is %t, desired %t", aws.BoolValue(eni.SourceDestCheck), *s.sourceDestCheck))
+	}
+
+	return drift, desiredGroups, nil
+}
+
+// desiredSecurityGroups resolves the configured security group IDs and tags
+// to a sorted list of IDs.
+func (s *ENI) desiredSecurityGroups(ec2Client ec2iface.EC2API, eni *ec2.NetworkInterface) ([]string, error) {
+	if len(s.securityGroupIDs) == 0 && len(s.securityGroupTags) == 0 {
+		return nil, nil
+	}
+
+	ids := map[string]bool{}
+	for _, id := range s.securityGroupIDs {
+		ids[id] = true
+	}
+
+	if len(s.securityGroupTags) > 0 {
+		filters := []*ec2.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []*string{eni.VpcId},
+			},
+		}
+		for _, t := range s.securityGroupTags {
+			kv := strings.SplitN(t, "=", 2)
+			if len(kv) != 2 {
+				return nil, microerror.Maskf(invalidConfigError, "security group tag %q must be in the form key=value", t)
+			}
+			filters = append(filters, &ec2.Filter{
+				Name:   tagKey(kv[0]),
+				Values: tagValue(kv[1]),
+			})
+		}
+
+		o, err := ec2Client.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{
+			Filters: filters,
+		})
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		if len(o.SecurityGroups) == 0 {
+			return nil, microerror.Maskf(executionFailedError, "no security group matches tags %s", s.securityGroupTags)
+		}
+		for _, g := range o.SecurityGroups {
+			ids[*g.GroupId] = true
+		}
+	}
+
+	var desired []string
+	for id := range ids {
+		desired = append(desired, id)
+	}
+	sort.Strings(desired)
+
+	return desired, nil
+}
+
+func eniGroupIDs(eni *ec2.NetworkInterface) []string {
+	var ids []string
+	for _, g := range eni.Groups {
+		ids = append(ids, *g.GroupId)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
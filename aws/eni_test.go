@@ -0,0 +1,206 @@
+package aws
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+)
+
+// fakeENIEC2 lists a fixed set of ENIs and records modifying requests. Any
+// other request panics as the embedded interface is nil.
+type fakeENIEC2 struct {
+	ec2iface.EC2API
+
+	enis []*ec2.NetworkInterface
+
+	attachRequests int
+	tagRequests    int
+}
+
+func (f *fakeENIEC2) DescribeNetworkInterfacesPages(input *ec2.DescribeNetworkInterfacesInput, fn func(*ec2.DescribeNetworkInterfacesOutput, bool) bool) error {
+	var enis []*ec2.NetworkInterface
+	for _, e := range f.enis {
+		match := true
+		for _, filter := range input.Filters {
+			if aws.StringValue(filter.Name) == "network-interface-id" && aws.StringValue(filter.Values[0]) != aws.StringValue(e.NetworkInterfaceId) {
+				match = false
+			}
+		}
+		if match {
+			enis = append(enis, e)
+		}
+	}
+	fn(&ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: enis}, true)
+	return nil
+}
+
+func (f *fakeENIEC2) AttachNetworkInterface(input *ec2.AttachNetworkInterfaceInput) (*ec2.AttachNetworkInterfaceOutput, error) {
+	f.attachRequests++
+	return &ec2.AttachNetworkInterfaceOutput{}, nil
+}
+
+func (f *fakeENIEC2) CreateTags(input *ec2.CreateTagsInput) (*ec2.CreateTagsOutput, error) {
+	f.tagRequests++
+	return &ec2.CreateTagsOutput{}, nil
+}
+
+func (f *fakeENIEC2) DeleteTags(input *ec2.DeleteTagsInput) (*ec2.DeleteTagsOutput, error) {
+	f.tagRequests++
+	return &ec2.DeleteTagsOutput{}, nil
+}
+
+func poolENI(id, status, attachedTo, claimedBy string) *ec2.NetworkInterface {
+	eni := &ec2.NetworkInterface{
+		AvailabilityZone:   aws.String("eu-west-1a"),
+		NetworkInterfaceId: aws.String(id),
+		Status:             aws.String(status),
+	}
+	if attachedTo != "" {
+		eni.Attachment = &ec2.NetworkInterfaceAttachment{InstanceId: aws.String(attachedTo)}
+	}
+	if claimedBy != "" {
+		eni.TagSet = []*ec2.Tag{{Key: aws.String(DefaultPoolClaimTagKey), Value: aws.String(claimedBy)}}
+	}
+	return eni
+}
+
+func Test_ENI_attachFromPool_alreadyAttached(t *testing.T) {
+	testCases := []struct {
+		name       string
+		enis       []*ec2.NetworkInterface
+		expectedID string
+	}{
+		{
+			name: "case 0: attached to this instance",
+			enis: []*ec2.NetworkInterface{
+				poolENI("eni-1", ec2.NetworkInterfaceStatusInUse, "i-other", "i-other"),
+				poolENI("eni-2", ec2.NetworkInterfaceStatusInUse, "i-me", "i-me"),
+			},
+			expectedID: "eni-2",
+		},
+		{
+			name: "case 1: attached to this instance without claim",
+			enis: []*ec2.NetworkInterface{
+				poolENI("eni-1", ec2.NetworkInterfaceStatusAvailable, "", ""),
+				poolENI("eni-2", ec2.NetworkInterfaceStatusInUse, "i-me", ""),
+			},
+			expectedID: "eni-2",
+		},
+		{
+			name: "case 2: attached to this instance while claiming another one",
+			enis: []*ec2.NetworkInterface{
+				poolENI("eni-1", ec2.NetworkInterfaceStatusAvailable, "", "i-me"),
+				poolENI("eni-2", ec2.NetworkInterfaceStatusInUse, "i-me", "i-other"),
+			},
+			expectedID: "eni-2",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := &fakeENIEC2{enis: tc.enis}
+			s := &ENI{
+				awsInstanceID:   "i-me",
+				filters:         []*ec2.Filter{{Name: aws.String("tag:etcd"), Values: []*string{aws.String("1")}}},
+				pool:            true,
+				poolClaimTagKey: DefaultPoolClaimTagKey,
+			}
+
+			eni, attached, err := s.attachFromPool(fake)
+			if err != nil {
+				t.Fatalf("unexpected error %#v", err)
+			}
+
+			// the caller still configures the routing of the returned eni,
+			// only the attach and the claim are skipped
+			if attached {
+				t.Fatalf("expected eni to be reported as already attached")
+			}
+			if aws.StringValue(eni.NetworkInterfaceId) != tc.expectedID {
+				t.Fatalf("expected eni %q, got %q", tc.expectedID, aws.StringValue(eni.NetworkInterfaceId))
+			}
+			if fake.attachRequests != 0 {
+				t.Fatalf("expected no attach requests, got %d", fake.attachRequests)
+			}
+			if fake.tagRequests != 0 {
+				t.Fatalf("expected no tag requests, got %d", fake.tagRequests)
+			}
+		})
+	}
+}
+
+func Test_subnetIPv6CIDR(t *testing.T) {
+	testCases := []struct {
+		name         string
+		associations []*ec2.SubnetIpv6CidrBlockAssociation
+		expectedCIDR string
+		expectedErr  bool
+	}{
+		{
+			name: "case 0: associated block",
+			associations: []*ec2.SubnetIpv6CidrBlockAssociation{
+				{
+					Ipv6CidrBlock:      aws.String("2001:db8:1:2::/64"),
+					Ipv6CidrBlockState: &ec2.SubnetCidrBlockState{State: aws.String(ec2.SubnetCidrBlockStateCodeAssociated)},
+				},
+			},
+			expectedCIDR: "2001:db8:1:2::/64",
+		},
+		{
+			name: "case 1: disassociated block is skipped",
+			associations: []*ec2.SubnetIpv6CidrBlockAssociation{
+				{
+					Ipv6CidrBlock:      aws.String("2001:db8:1:1::/64"),
+					Ipv6CidrBlockState: &ec2.SubnetCidrBlockState{State: aws.String(ec2.SubnetCidrBlockStateCodeDisassociated)},
+				},
+				{
+					Ipv6CidrBlock:      aws.String("2001:db8:1:2::/64"),
+					Ipv6CidrBlockState: &ec2.SubnetCidrBlockState{State: aws.String(ec2.SubnetCidrBlockStateCodeAssociated)},
+				},
+			},
+			expectedCIDR: "2001:db8:1:2::/64",
+		},
+		{
+			name: "case 2: missing state is skipped",
+			associations: []*ec2.SubnetIpv6CidrBlockAssociation{
+				{
+					Ipv6CidrBlock:      aws.String("2001:db8:1:1::/64"),
+					Ipv6CidrBlockState: &ec2.SubnetCidrBlockState{},
+				},
+				{
+					Ipv6CidrBlock: aws.String("2001:db8:1:3::/64"),
+				},
+			},
+			expectedErr: true,
+		},
+		{
+			name:        "case 3: no IPv6 block",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			subnet := &ec2.Subnet{
+				Ipv6CidrBlockAssociationSet: tc.associations,
+				SubnetId:                    aws.String("subnet-1"),
+			}
+
+			ipNet, err := subnetIPv6CIDR(subnet)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected error, got %s", ipNet)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %#v", err)
+			}
+			if ipNet.String() != tc.expectedCIDR {
+				t.Fatalf("expected %q, got %q", tc.expectedCIDR, ipNet.String())
+			}
+		})
+	}
+}
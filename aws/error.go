@@ -14,3 +14,21 @@ var invalidConfigError = &microerror.Error{
 func IsInvalidConfig(err error) bool {
 	return microerror.Cause(err) == invalidConfigError
 }
+
+var ambiguousMatchError = &microerror.Error{
+	Kind: "ambiguousMatchError",
+}
+
+// IsAmbiguousMatch asserts ambiguousMatchError.
+func IsAmbiguousMatch(err error) bool {
+	return microerror.Cause(err) == ambiguousMatchError
+}
+
+var resourceInUseError = &microerror.Error{
+	Kind: "resourceInUseError",
+}
+
+// IsResourceInUse asserts resourceInUseError.
+func IsResourceInUse(err error) bool {
+	return microerror.Cause(err) == resourceInUseError
+}
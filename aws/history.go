@@ -0,0 +1,95 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/aws-attach-etcd-dep/pkg/project"
+)
+
+const (
+	// detach modes recorded in the history, auto means the resource was
+	// released by the previous instance by itself
+	detachModeAuto   = "auto"
+	detachModeForce  = "force"
+	detachModeManual = "manual"
+
+	historyTagAttachHistory        = "aws-attach-etcd-dep/attach-history"
+	historyTagLastAttachedAt       = "aws-attach-etcd-dep/last-attached-at"
+	historyTagLastAttachedInstance = "aws-attach-etcd-dep/last-attached-instance"
+	historyTagLastDetachMode       = "aws-attach-etcd-dep/last-detach-mode"
+	historyTagToolVersion          = "aws-attach-etcd-dep/tool-version"
+
+	// EC2 limits tag values to 256 characters, the attach history keeps as
+	// many of the latest entries as fit
+	historyTagMaxLength = 256
+)
+
+// AttachmentHistory is recorded in the tags of the ENI and the volume after
+// each attach.
+type AttachmentHistory struct {
+	// Entries are the latest attachments as instance@time, newest first.
+	Entries              []string
+	LastAttachedAt       string
+	LastAttachedInstance string
+	LastDetachMode       string
+	ToolVersion          string
+}
+
+func attachmentHistory(tags []*ec2.Tag) AttachmentHistory {
+	return AttachmentHistory{
+		Entries:              strings.Fields(tagValueOf(tags, historyTagAttachHistory)),
+		LastAttachedAt:       tagValueOf(tags, historyTagLastAttachedAt),
+		LastAttachedInstance: tagValueOf(tags, historyTagLastAttachedInstance),
+		LastDetachMode:       tagValueOf(tags, historyTagLastDetachMode),
+		ToolVersion:          tagValueOf(tags, historyTagToolVersion),
+	}
+}
+
+// recordAttachment writes the attachment history tags of the resource. The
+// detach mode is empty if the resource was not attached to another instance.
+func recordAttachment(ec2Client ec2iface.EC2API, resourceID string, tags []*ec2.Tag, instanceID string, detachMode string) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	entries := []string{fmt.Sprintf("%s@%s", instanceID, now)}
+	entries = append(entries, attachmentHistory(tags).Entries...)
+	for len(entries) > 1 && len(strings.Join(entries, " ")) > historyTagMaxLength {
+		entries = entries[:len(entries)-1]
+	}
+
+	if detachMode == "" {
+		detachMode = "none"
+	}
+
+	newTags := []*ec2.Tag{
+		{Key: aws.String(historyTagAttachHistory), Value: aws.String(strings.Join(entries, " "))},
+		{Key: aws.String(historyTagLastAttachedAt), Value: aws.String(now)},
+		{Key: aws.String(historyTagLastAttachedInstance), Value: aws.String(instanceID)},
+		{Key: aws.String(historyTagLastDetachMode), Value: aws.String(detachMode)},
+		{Key: aws.String(historyTagToolVersion), Value: aws.String(truncate(project.Version(), historyTagMaxLength))},
+	}
+
+	_, err := ec2Client.CreateTags(&ec2.CreateTagsInput{
+		Resources: []*string{aws.String(resourceID)},
+		Tags:      newTags,
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	fmt.Printf("Recorded attachment of %q to %q in tags.\n", resourceID, instanceID)
+	return nil
+}
+
+func truncate(s string, length int) string {
+	if len(s) > length {
+		return s[:length]
+	}
+	return s
+}
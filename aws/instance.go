@@ -0,0 +1,106 @@
+package aws
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/giantswarm/microerror"
+)
+
+// slot identifies the place where a network interface is attached to an
+// instance.
+type slot struct {
+	deviceIndex      int64
+	networkCardIndex int64
+}
+
+func describeInstance(ec2Client ec2iface.EC2API, instanceID string) (*ec2.Instance, error) {
+	o, err := ec2Client.DescribeInstances(&ec2.DescribeInstancesInput{
+		InstanceIds: []*string{aws.String(instanceID)},
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	// id should give us only one unique instance
+	if len(o.Reservations) != 1 || len(o.Reservations[0].Instances) != 1 {
+		return nil, microerror.Maskf(executionFailedError, "expected 1 instance for instanceID %#q", instanceID)
+	}
+
+	return o.Reservations[0].Instances[0], nil
+}
+
+// freeSlot returns the lowest free device index on the lowest network card
+// with free capacity. Device index 0 is never returned as it is reserved for
+// the primary network interface.
+func freeSlot(ec2Client ec2iface.EC2API, instanceID string, networkCardIndex int64) (slot, error) {
+	instance, err := describeInstance(ec2Client, instanceID)
+	if err != nil {
+		return slot{}, microerror.Mask(err)
+	}
+
+	o, err := ec2Client.DescribeInstanceTypes(&ec2.DescribeInstanceTypesInput{
+		InstanceTypes: []*string{instance.InstanceType},
+	})
+	if err != nil {
+		return slot{}, microerror.Mask(err)
+	}
+	if len(o.InstanceTypes) != 1 || o.InstanceTypes[0].NetworkInfo == nil {
+		return slot{}, microerror.Maskf(executionFailedError, "expected network info for instance type %#q", *instance.InstanceType)
+	}
+	networkInfo := o.InstanceTypes[0].NetworkInfo
+
+	// maximum number of interfaces per network card
+	capacity := map[int64]int64{}
+	for _, c := range networkInfo.NetworkCards {
+		capacity[*c.NetworkCardIndex] = *c.MaximumNetworkInterfaces
+	}
+	if len(capacity) == 0 {
+		capacity[0] = *networkInfo.MaximumNetworkInterfaces
+	}
+
+	used := map[slot]bool{}
+	for _, i := range instance.NetworkInterfaces {
+		if i.Attachment == nil {
+			continue
+		}
+		used[slot{
+			deviceIndex:      aws.Int64Value(i.Attachment.DeviceIndex),
+			networkCardIndex: aws.Int64Value(i.Attachment.NetworkCardIndex),
+		}] = true
+	}
+
+	var cards []int64
+	for c := range capacity {
+		if networkCardIndex >= 0 && c != networkCardIndex {
+			continue
+		}
+		cards = append(cards, c)
+	}
+	sort.Slice(cards, func(i, j int) bool { return cards[i] < cards[j] })
+
+	for _, c := range cards {
+		var attached int64
+		for s := range used {
+			if s.networkCardIndex == c {
+				attached++
+			}
+		}
+		if attached >= capacity[c] {
+			continue
+		}
+
+		for d := int64(1); ; d++ {
+			candidate := slot{deviceIndex: d, networkCardIndex: c}
+			if !used[candidate] {
+				fmt.Printf("Selected free device index %d on network card %d.\n", d, c)
+				return candidate, nil
+			}
+		}
+	}
+
+	return slot{}, microerror.Maskf(executionFailedError, "instance %#q has no free network interface slot", instanceID)
+}
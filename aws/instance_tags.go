@@ -0,0 +1,40 @@
+package aws
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/giantswarm/microerror"
+)
+
+// GetInstanceTags returns the tags of the instance using DescribeTags, it is
+// used when tags are not available in instance metadata.
+func GetInstanceTags(awsSession *session.Session, instanceID string) (map[string]string, error) {
+	ec2Client := ec2.New(awsSession)
+
+	i := &ec2.DescribeTagsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("resource-id"),
+				Values: []*string{aws.String(instanceID)},
+			},
+			{
+				Name:   aws.String("resource-type"),
+				Values: []*string{aws.String(ec2.ResourceTypeInstance)},
+			},
+		},
+	}
+
+	tags := map[string]string{}
+	err := ec2Client.DescribeTagsPages(i, func(o *ec2.DescribeTagsOutput, lastPage bool) bool {
+		for _, t := range o.Tags {
+			tags[*t.Key] = *t.Value
+		}
+		return true
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return tags, nil
+}
@@ -2,9 +2,12 @@ package aws
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/giantswarm/microerror"
 )
 
 const (
@@ -15,8 +18,23 @@ const (
 
 	attachRequestRetries = 5
 
+	// the status command only reads, so it gives up quickly instead of
+	// waiting for the resources to appear
+	statusMaxRetries    = 2
+	statusRetryInterval = time.Second * 2
+
 	// wait for detach for 30 mins
 	waitAutoDetachMaxRetries = 120
+	// wait for a manual detach for 10 mins before forcing it
+	waitManualDetachMaxRetries = 40
+)
+
+// volume detach states, see EBS.detach
+const (
+	detachStateDone    = "done"
+	detachStateForced  = "forced"
+	detachStateManual  = "manual"
+	detachStateWaiting = "waiting"
 )
 
 func tagKey(input string) *string {
@@ -26,3 +44,53 @@ func tagKey(input string) *string {
 func tagValue(input string) []*string {
 	return []*string{aws.String(input)}
 }
+
+// buildFilters returns the EC2 filters matching the resource to attach. The
+// tag pair is optional if other filters are given. Filters are given as
+// name=value, e.g. tag:etcd-member=1 or availability-zone=eu-west-1a, values
+// may contain the * and ? wildcards. Filters with the same name are merged so
+// any of their values matches, different names must all match.
+func buildFilters(key string, value string, filters []string) ([]*ec2.Filter, error) {
+	if (key == "") != (value == "") {
+		return nil, microerror.Maskf(invalidConfigError, "tag key and tag value must be set together")
+	}
+	if key == "" && len(filters) == 0 {
+		return nil, microerror.Maskf(invalidConfigError, "tag or filters must not be empty")
+	}
+
+	var result []*ec2.Filter
+	byName := map[string]*ec2.Filter{}
+	add := func(name string, value string) {
+		if f, ok := byName[name]; ok {
+			f.Values = append(f.Values, aws.String(value))
+			return
+		}
+		f := &ec2.Filter{
+			Name:   aws.String(name),
+			Values: []*string{aws.String(value)},
+		}
+		byName[name] = f
+		result = append(result, f)
+	}
+
+	if key != "" {
+		add(*tagKey(key), value)
+	}
+	for _, f := range filters {
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, microerror.Maskf(invalidConfigError, "filter %q must be in the form name=value", f)
+		}
+		add(kv[0], kv[1])
+	}
+
+	return result, nil
+}
+
+func filtersString(filters []*ec2.Filter) string {
+	var parts []string
+	for _, f := range filters {
+		parts = append(parts, fmt.Sprintf("%s=%s", *f.Name, strings.Join(aws.StringValueSlice(f.Values), "|")))
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,242 @@
+package aws
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	// DefaultPoolClaimTagKey is the tag holding the ID of the instance which
+	// claimed a resource of the pool.
+	DefaultPoolClaimTagKey = "aws-attach-etcd-dep/claimed-by"
+
+	// wait for concurrent claims to settle before verifying our claim
+	claimSettleInterval = time.Second * 5
+)
+
+// poolCandidate is a volume or ENI matching the pool filters.
+type poolCandidate struct {
+	attachedTo       []string
+	availabilityZone string
+	claimedBy        string
+	id               string
+	state            string
+}
+
+// pool picks a resource from all resources matching the filters. Tags are the
+// only way to mark a resource, EC2 offers no conditional tagging, so a claim
+// is written, given time to settle and read back. If another instance
+// overwrote it in the meantime the next candidate is tried. As two instances
+// may still both believe to hold a claim, the attach request decides: the
+// instance whose attach fails because the resource is in use releases its
+// claim and moves on.
+type pool struct {
+	availableState string
+	claimTagKey    string
+	ec2Client      ec2iface.EC2API
+	instanceID     string
+}
+
+// mine returns the candidate already attached to or claimed by this instance.
+// A claim on a candidate attached to another instance lost the race for it
+// and is ignored.
+func (p pool) mine(candidates []poolCandidate) (string, bool) {
+	for _, c := range candidates {
+		for _, i := range c.attachedTo {
+			if i == p.instanceID {
+				return c.id, true
+			}
+		}
+	}
+	for _, c := range candidates {
+		if c.claimedBy == p.instanceID && len(c.attachedTo) == 0 {
+			return c.id, true
+		}
+	}
+	return "", false
+}
+
+// claim returns the ID of the resource claimed by this instance. Free
+// candidates are available, in the AZ of the instance and not claimed by
+// another running instance. They are tried in a deterministic order, which
+// starts at a different candidate per instance to reduce conflicts.
+func (p pool) claim(candidates []poolCandidate) (string, error) {
+	if id, ok := p.mine(candidates); ok {
+		fmt.Printf("Resource %q of the pool is already attached to or claimed by this instance.\n", id)
+		return id, nil
+	}
+
+	instance, err := describeInstance(p.ec2Client, p.instanceID)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+	az := aws.StringValue(instance.Placement.AvailabilityZone)
+
+	var free []poolCandidate
+	for _, c := range candidates {
+		if c.state != p.availableState || c.availabilityZone != az {
+			continue
+		}
+		if c.claimedBy != "" {
+			active, err := p.isInstanceActive(c.claimedBy)
+			if err != nil {
+				return "", microerror.Mask(err)
+			}
+			if active {
+				fmt.Printf("Resource %q is claimed by running instance %q, skipping.\n", c.id, c.claimedBy)
+				continue
+			}
+			fmt.Printf("Resource %q has a stale claim of instance %q.\n", c.id, c.claimedBy)
+		}
+		free = append(free, c)
+	}
+	if len(free) == 0 {
+		return "", microerror.Maskf(executionFailedError, "no free resource in pool of %d in availability zone %q", len(candidates), az)
+	}
+
+	sort.Slice(free, func(i, j int) bool {
+		return free[i].id < free[j].id
+	})
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(p.instanceID))
+	start := int(h.Sum32() % uint32(len(free)))
+
+	for i := 0; i < len(free); i++ {
+		c := free[(start+i)%len(free)]
+
+		claimed, err := p.claimCandidate(c.id)
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+		if claimed {
+			fmt.Printf("Claimed resource %q of the pool.\n", c.id)
+			return c.id, nil
+		}
+	}
+
+	return "", microerror.Maskf(executionFailedError, "all %d free resources of the pool were claimed by other instances", len(free))
+}
+
+func (p pool) claimCandidate(id string) (bool, error) {
+	// the candidates were listed a while ago, by now another instance may
+	// have claimed this one
+	claimedBy, err := p.claimOf(id)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+	if claimedBy != "" && claimedBy != p.instanceID {
+		active, err := p.isInstanceActive(claimedBy)
+		if err != nil {
+			return false, microerror.Mask(err)
+		}
+		if active {
+			fmt.Printf("Resource %q was claimed by running instance %q in the meantime, trying next.\n", id, claimedBy)
+			return false, nil
+		}
+	}
+
+	_, err = p.ec2Client.CreateTags(&ec2.CreateTagsInput{
+		Resources: []*string{aws.String(id)},
+		Tags: []*ec2.Tag{
+			{
+				Key:   aws.String(p.claimTagKey),
+				Value: aws.String(p.instanceID),
+			},
+		},
+	})
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	time.Sleep(claimSettleInterval)
+
+	claimedBy, err = p.claimOf(id)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+	if claimedBy != p.instanceID {
+		fmt.Printf("Claim of resource %q conflicted with another instance, trying next.\n", id)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// claimOf reads the current claim of the resource, empty if unclaimed.
+func (p pool) claimOf(id string) (string, error) {
+	o, err := p.ec2Client.DescribeTags(&ec2.DescribeTagsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("resource-id"),
+				Values: []*string{aws.String(id)},
+			},
+			{
+				Name:   aws.String("key"),
+				Values: []*string{aws.String(p.claimTagKey)},
+			},
+		},
+	})
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+	if len(o.Tags) != 1 {
+		return "", nil
+	}
+
+	return aws.StringValue(o.Tags[0].Value), nil
+}
+
+// release removes the claim of this instance from a resource another
+// instance attached first. The tag is only deleted if it still holds our
+// instance ID, so the claim of the winner is kept.
+func (p pool) release(id string) error {
+	_, err := p.ec2Client.DeleteTags(&ec2.DeleteTagsInput{
+		Resources: []*string{aws.String(id)},
+		Tags: []*ec2.Tag{
+			{
+				Key:   aws.String(p.claimTagKey),
+				Value: aws.String(p.instanceID),
+			},
+		},
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	fmt.Printf("Released claim of resource %q which is in use by another instance.\n", id)
+	return nil
+}
+
+// isInstanceActive returns false for claims of instances which are gone or
+// stopped, so their resources can be claimed again.
+func (p pool) isInstanceActive(instanceID string) (bool, error) {
+	instance, err := describeInstance(p.ec2Client, instanceID)
+	if aerr, ok := microerror.Cause(err).(awserr.Error); ok && aerr.Code() == "InvalidInstanceID.NotFound" {
+		return false, nil
+	} else if microerror.Cause(err) == executionFailedError {
+		// terminated instances vanish from DescribeInstances after a while
+		return false, nil
+	} else if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	state := aws.StringValue(instance.State.Name)
+	return state == ec2.InstanceStateNamePending || state == ec2.InstanceStateNameRunning, nil
+}
+
+func tagValueOf(tags []*ec2.Tag, key string) string {
+	for _, t := range tags {
+		if aws.StringValue(t.Key) == key {
+			return aws.StringValue(t.Value)
+		}
+	}
+	return ""
+}
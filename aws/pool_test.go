@@ -0,0 +1,55 @@
+package aws
+
+import (
+	"testing"
+)
+
+func Test_pool_mine(t *testing.T) {
+	testCases := []struct {
+		name       string
+		candidates []poolCandidate
+		expectedID string
+		expectedOK bool
+	}{
+		{
+			name: "case 0: attached to this instance wins over a claim",
+			candidates: []poolCandidate{
+				{id: "vol-1", claimedBy: "i-me"},
+				{id: "vol-2", attachedTo: []string{"i-me"}, claimedBy: "i-other"},
+			},
+			expectedID: "vol-2",
+			expectedOK: true,
+		},
+		{
+			name: "case 1: claimed and not attached",
+			candidates: []poolCandidate{
+				{id: "vol-1", claimedBy: "i-other"},
+				{id: "vol-2", claimedBy: "i-me"},
+			},
+			expectedID: "vol-2",
+			expectedOK: true,
+		},
+		{
+			name: "case 2: claim on a resource attached elsewhere is ignored",
+			candidates: []poolCandidate{
+				{id: "vol-1", attachedTo: []string{"i-other"}, claimedBy: "i-me"},
+			},
+		},
+		{
+			name: "case 3: nothing attached or claimed",
+			candidates: []poolCandidate{
+				{id: "vol-1"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := pool{instanceID: "i-me"}
+			id, ok := p.mine(tc.candidates)
+			if ok != tc.expectedOK || id != tc.expectedID {
+				t.Fatalf("expected %q, %t, got %q, %t", tc.expectedID, tc.expectedOK, id, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package aws
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/giantswarm/microerror"
+)
+
+// ENIStatus describes the current state of the ENI found by tag.
+type ENIStatus struct {
+	AttachedInstance string
+	Drift            []string
+	History          AttachmentHistory
+	ID               string
+	PrivateIP        string
+	SecurityGroups   []string
+	SourceDestCheck  bool
+	State            string
+}
+
+// EBSStatus describes the current state of the volume found by tag.
+type EBSStatus struct {
+	AttachedInstances []string
+	AvailabilityZone  string
+	History           AttachmentHistory
+	ID                string
+	State             string
+}
+
+// Status describes the ENI without modifying it.
+func (s *ENI) Status() (ENIStatus, error) {
+	ec2Client := ec2.New(s.awsSession)
+
+	eni, err := s.describe(ec2Client, statusMaxRetries, statusRetryInterval)
+	if err != nil {
+		return ENIStatus{}, microerror.Mask(err)
+	}
+
+	drift, _, err := s.attributeDrift(ec2Client, eni)
+	if err != nil {
+		return ENIStatus{}, microerror.Mask(err)
+	}
+
+	status := ENIStatus{
+		Drift:           drift,
+		History:         attachmentHistory(eni.TagSet),
+		ID:              aws.StringValue(eni.NetworkInterfaceId),
+		PrivateIP:       aws.StringValue(eni.PrivateIpAddress),
+		SecurityGroups:  eniGroupIDs(eni),
+		SourceDestCheck: aws.BoolValue(eni.SourceDestCheck),
+		State:           aws.StringValue(eni.Status),
+	}
+	if eni.Attachment != nil {
+		status.AttachedInstance = aws.StringValue(eni.Attachment.InstanceId)
+	}
+
+	return status, nil
+}
+
+// Status describes the volume without modifying it.
+func (s *EBS) Status() (EBSStatus, error) {
+	ec2Client := s.ec2Client
+
+	volume, err := s.describe(ec2Client)
+	if err != nil {
+		return EBSStatus{}, microerror.Mask(err)
+	}
+
+	status := EBSStatus{
+		AvailabilityZone: aws.StringValue(volume.AvailabilityZone),
+		History:          attachmentHistory(volume.Tags),
+		ID:               aws.StringValue(volume.VolumeId),
+		State:            aws.StringValue(volume.State),
+	}
+	for _, a := range volume.Attachments {
+		status.AttachedInstances = append(status.AttachedInstances, aws.StringValue(a.InstanceId))
+	}
+
+	return status, nil
+}
@@ -2,32 +2,71 @@ package aws
 
 import (
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/giantswarm/backoff"
 	"github.com/giantswarm/microerror"
 )
 
+const (
+	// MultiAttachPolicyExclusive detaches all other instances from a
+	// Multi-Attach volume before attaching it.
+	MultiAttachPolicyExclusive = "exclusive"
+	// MultiAttachPolicyRefuse fails if a Multi-Attach volume is attached to
+	// another instance.
+	MultiAttachPolicyRefuse = "refuse"
+	// MultiAttachPolicyShare attaches a Multi-Attach volume alongside the
+	// instances it is already attached to.
+	MultiAttachPolicyShare = "share"
+)
+
 type EBSConfig struct {
 	AWSInstanceID string
 	AwsSession    *session.Session
 	DeviceName    string
-	ForceDetach   bool
-	TagKey        string
-	TagValue      string
+	// EC2Client is optional and created from AwsSession if nil, it allows to
+	// use a local stand-in for EC2.
+	EC2Client ec2iface.EC2API
+	// Filters are additional EC2 filters given as name=value, see
+	// buildFilters.
+	Filters     []string
+	ForceDetach bool
+	// MultiAttachPolicy defines how a volume with Multi-Attach enabled which
+	// is attached to other instances is handled, see the MultiAttachPolicy
+	// constants. Volumes without Multi-Attach are always detached.
+	MultiAttachPolicy string
+	// Pool allows the filters to match several volumes, an available one in
+	// the availability zone of the instance is claimed by setting
+	// PoolClaimTagKey to the instance ID.
+	Pool            bool
+	PoolClaimTagKey string
+	TagKey          string
+	TagValue        string
+	// TieBreakers select one of several volumes matching the filters, they
+	// are applied in the given order, see the TieBreaker constants.
+	TieBreakers []string
 }
 
 type EBS struct {
-	awsInstanceID string
-	awsSession    *session.Session
-	deviceName    string
-	forceDetach   bool
-	tagKey        string
-	tagValue      string
+	awsInstanceID   string
+	awsSession      *session.Session
+	deviceName      string
+	ec2Client       ec2iface.EC2API
+	filters         []*ec2.Filter
+	forceDetach     bool
+	multiAttach     string
+	pool            bool
+	poolClaimTagKey string
+	retryInterval   time.Duration
+	tieBreakers     []string
+
+	forceDetached bool
+	volume        *ec2.Volume
 }
 
 func NewEBS(config EBSConfig) (*EBS, error) {
@@ -40,43 +79,94 @@ func NewEBS(config EBSConfig) (*EBS, error) {
 	if config.DeviceName == "" {
 		return nil, microerror.Maskf(invalidConfigError, "config.DeviceName must not be empty")
 	}
-	if config.TagKey == "" {
-		return nil, microerror.Maskf(invalidConfigError, "config.TagKey must not be empty")
+	if config.MultiAttachPolicy != MultiAttachPolicyExclusive && config.MultiAttachPolicy != MultiAttachPolicyRefuse && config.MultiAttachPolicy != MultiAttachPolicyShare {
+		return nil, microerror.Maskf(invalidConfigError, "config.MultiAttachPolicy must be one of %q, %q or %q", MultiAttachPolicyExclusive, MultiAttachPolicyRefuse, MultiAttachPolicyShare)
+	}
+	if config.Pool && config.PoolClaimTagKey == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.PoolClaimTagKey must not be empty in pool mode")
+	}
+	filters, err := buildFilters(config.TagKey, config.TagValue, config.Filters)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	err = validateTieBreakers(config.TieBreakers, true)
+	if err != nil {
+		return nil, microerror.Mask(err)
 	}
-	if config.TagValue == "" {
-		return nil, microerror.Maskf(invalidConfigError, "config.TagValue must not be empty")
+
+	ec2Client := config.EC2Client
+	if ec2Client == nil {
+		ec2Client = ec2.New(config.AwsSession)
 	}
 
 	newEBS := &EBS{
-		awsInstanceID: config.AWSInstanceID,
-		awsSession:    config.AwsSession,
-		deviceName:    config.DeviceName,
-		forceDetach:   config.ForceDetach,
-		tagKey:        config.TagKey,
-		tagValue:      config.TagValue,
+		awsInstanceID:   config.AWSInstanceID,
+		awsSession:      config.AwsSession,
+		deviceName:      config.DeviceName,
+		ec2Client:       ec2Client,
+		filters:         filters,
+		forceDetach:     config.ForceDetach,
+		multiAttach:     config.MultiAttachPolicy,
+		pool:            config.Pool,
+		poolClaimTagKey: config.PoolClaimTagKey,
+		retryInterval:   retryInterval,
+		tieBreakers:     config.TieBreakers,
 	}
 	return newEBS, nil
 }
 
 func (s *EBS) AttachByTag() error {
-	// create ec2 client
-	ec2Client := ec2.New(s.awsSession)
+	var detachMode string
+	var err error
+
+	ec2Client := s.ec2Client
+
+	if s.pool {
+		volume, attached, err := s.attachFromPool(ec2Client)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		s.volume = volume
+		if !attached {
+			fmt.Printf("Volume is already attached to this instance. Nothing to do.\n")
+			return nil
+		}
+
+		err = recordAttachment(ec2Client, *volume.VolumeId, volume.Tags, s.awsInstanceID, "")
+		if err != nil {
+			fmt.Printf("Failed to record attachment history of volume %q, err: %s.\n", *volume.VolumeId, err)
+		}
+		return nil
+	}
 
 	volume, err := s.describe(ec2Client)
 	if err != nil {
 		return microerror.Mask(err)
 	}
 	fmt.Printf("Fetched volume-id '%s'\n", *volume.VolumeId)
+	s.volume = volume
+
+	var others []string
+	for _, a := range volume.Attachments {
+		if *a.InstanceId != s.awsInstanceID {
+			others = append(others, *a.InstanceId)
+		}
+	}
+	multiAttach := aws.BoolValue(volume.MultiAttachEnabled)
 
 	if *volume.State == ec2.VolumeStateInUse &&
-		len(volume.Attachments) == 1 &&
-		*volume.Attachments[0].InstanceId == s.awsInstanceID {
+		len(others) < len(volume.Attachments) {
+		// with Multi-Attach the volume stays attached to the others
 		fmt.Printf("Volume is already attached to this instance. Nothing to do.\n")
 		return nil
+	} else if *volume.State == ec2.VolumeStateInUse && multiAttach && s.multiAttach == MultiAttachPolicyRefuse {
+		return microerror.Maskf(executionFailedError, "Multi-Attach volume %q is attached to %v, refusing to attach it", *volume.VolumeId, others)
+	} else if *volume.State == ec2.VolumeStateInUse && multiAttach && s.multiAttach == MultiAttachPolicyShare {
+		fmt.Printf("Multi-Attach volume is attached to %v, attaching it alongside.\n", others)
 	} else if *volume.State == ec2.VolumeStateInUse {
 		fmt.Printf("Volume is attached to %q and is in state %q. Trying detach the volume\n", *volume.Attachments[0].InstanceId, *volume.State)
 
-		err := s.detach(ec2Client, volume)
+		detachMode, err = s.detach(ec2Client, volume)
 		if err != nil {
 			return microerror.Mask(err)
 		}
@@ -88,44 +178,237 @@ func (s *EBS) AttachByTag() error {
 	if err != nil {
 		return microerror.Mask(err)
 	}
+
+	// the history is for auditing only, failing to record it must not stop
+	// the volume from being used
+	err = recordAttachment(ec2Client, *volume.VolumeId, volume.Tags, s.awsInstanceID, detachMode)
+	if err != nil {
+		fmt.Printf("Failed to record attachment history of volume %q, err: %s.\n", *volume.VolumeId, err)
+	}
+
 	return nil
 }
 
-func (s *EBS) describe(ec2Client *ec2.EC2) (*ec2.Volume, error) {
-	volumeFilter := &ec2.Filter{
-		Name:   tagKey(s.tagKey),
-		Values: tagValue(s.tagValue),
+// ForceDetached returns true if the volume had to be force-detached from
+// another instance by AttachByTag.
+func (s *EBS) ForceDetached() bool {
+	return s.forceDetached
+}
+
+// ID returns the ID of the volume found by AttachByTag.
+func (s *EBS) ID() string {
+	if s.volume == nil {
+		return ""
 	}
+	return *s.volume.VolumeId
+}
 
-	describeVolumeInput := &ec2.DescribeVolumesInput{
-		Filters: []*ec2.Filter{
-			volumeFilter,
-		},
+// Tag returns the value of the given tag of the volume found by AttachByTag.
+func (s *EBS) Tag(key string) (string, bool) {
+	if s.volume == nil {
+		return "", false
+	}
+	for _, t := range s.volume.Tags {
+		if *t.Key == key {
+			return *t.Value, true
+		}
 	}
-	o, err := ec2Client.DescribeVolumes(describeVolumeInput)
+	return "", false
+}
+
+func (s *EBS) describe(ec2Client ec2iface.EC2API) (*ec2.Volume, error) {
+	volumes, err := s.describeVolumes(ec2Client, s.filters)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
 
+	// in pool mode the volume of this instance is the one attached to or
+	// claimed by it
+	if s.pool {
+		id, ok := s.newPool(ec2Client).mine(volumeCandidates(volumes, s.poolClaimTagKey))
+		if !ok {
+			return nil, microerror.Maskf(executionFailedError, "no volume matching %s is attached to or claimed by this instance", filtersString(s.filters))
+		}
+		for _, v := range volumes {
+			if *v.VolumeId == id {
+				return v, nil
+			}
+		}
+	}
+
+	if len(volumes) == 0 {
+		return nil, microerror.Maskf(executionFailedError, "expected 1 volume matching %s but got none", filtersString(s.filters))
+	}
 	// tags should give us only one unique volume
-	if len(o.Volumes) != 1 {
-		return nil, microerror.Maskf(executionFailedError, "expected 1 volume but got %d instead", len(o.Volumes))
+	if len(volumes) == 1 {
+		return volumes[0], nil
+	}
+
+	var matches []match
+	for _, v := range volumes {
+		m := match{
+			availabilityZone: aws.StringValue(v.AvailabilityZone),
+			createTime:       aws.TimeValue(v.CreateTime),
+			id:               aws.StringValue(v.VolumeId),
+			state:            aws.StringValue(v.State),
+		}
+		for _, a := range v.Attachments {
+			m.attachedTo = append(m.attachedTo, aws.StringValue(a.InstanceId))
+		}
+		matches = append(matches, m)
+	}
+	id, err := breakTie(ec2Client, s.awsInstanceID, "volume", matches, s.tieBreakers)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	for _, v := range volumes {
+		if *v.VolumeId == id {
+			return v, nil
+		}
+	}
+
+	return nil, microerror.Maskf(executionFailedError, "selected volume %q not found", id)
+}
+
+// attachFromPool claims a volume of the pool and attaches it, retrying until
+// one is free, e.g. when the instance it was attached to is replaced. A
+// volume of the pool is never detached from another instance, if another
+// instance attached the claimed volume first the claim is released and the
+// next free volume is claimed. It returns false if the volume was already
+// attached to this instance.
+func (s *EBS) attachFromPool(ec2Client ec2iface.EC2API) (*ec2.Volume, bool, error) {
+	p := s.newPool(ec2Client)
+
+	var volume *ec2.Volume
+	var attached bool
+	b := backoff.NewMaxRetries(maxRetries, s.retryInterval)
+	o := func() error {
+		volumes, err := s.describeVolumes(ec2Client, s.filters)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		id, err := p.claim(volumeCandidates(volumes, s.poolClaimTagKey))
+		if err != nil {
+			fmt.Printf("Failed to claim volume from pool, retrying in %ds, err: %s.\n", s.retryInterval/time.Second, err)
+			return microerror.Mask(err)
+		}
+
+		volume, err = s.describeByID(ec2Client, id)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		fmt.Printf("Fetched volume-id '%s'\n", id)
+
+		for _, a := range volume.Attachments {
+			if *a.InstanceId == s.awsInstanceID {
+				return nil
+			}
+		}
+
+		if *volume.State == ec2.VolumeStateAvailable {
+			err = s.attach(ec2Client, s.awsInstanceID, id)
+		} else {
+			err = microerror.Maskf(resourceInUseError, "volume %q is in state %q", id, *volume.State)
+		}
+		if IsResourceInUse(err) {
+			fmt.Printf("Volume %q was attached by another instance first, retrying in %ds.\n", id, s.retryInterval/time.Second)
+			releaseErr := p.release(id)
+			if releaseErr != nil {
+				return microerror.Mask(releaseErr)
+			}
+			return microerror.Mask(err)
+		} else if err != nil {
+			return backoff.Permanent(microerror.Mask(err))
+		}
+
+		attached = true
+		return nil
+	}
+	err := backoff.Retry(o, b)
+	if err != nil {
+		fmt.Printf("Failed to attach volume from pool after %d retries.\n", maxRetries)
+		return nil, false, microerror.Mask(err)
+	}
+
+	return volume, attached, nil
+}
+
+func (s *EBS) describeByID(ec2Client ec2iface.EC2API, volumeID string) (*ec2.Volume, error) {
+	volumes, err := s.describeVolumes(ec2Client, []*ec2.Filter{
+		{
+			Name:   aws.String("volume-id"),
+			Values: []*string{aws.String(volumeID)},
+		},
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	// id should give us only one unique volume
+	if len(volumes) != 1 {
+		return nil, microerror.Maskf(executionFailedError, "expected 1 volume for volume-id %#q but got %d instead", volumeID, len(volumes))
+	}
+
+	return volumes[0], nil
+}
+
+func (s *EBS) describeVolumes(ec2Client ec2iface.EC2API, filters []*ec2.Filter) ([]*ec2.Volume, error) {
+	describeVolumeInput := &ec2.DescribeVolumesInput{
+		Filters: filters,
+	}
+	var volumes []*ec2.Volume
+	err := ec2Client.DescribeVolumesPages(describeVolumeInput, func(o *ec2.DescribeVolumesOutput, lastPage bool) bool {
+		volumes = append(volumes, o.Volumes...)
+		return true
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
 	}
 
-	return o.Volumes[0], nil
+	return volumes, nil
 }
 
-func (s *EBS) attach(ec2Client *ec2.EC2, instanceID string, volumeID string) error {
+func (s *EBS) newPool(ec2Client ec2iface.EC2API) pool {
+	return pool{
+		availableState: ec2.VolumeStateAvailable,
+		claimTagKey:    s.poolClaimTagKey,
+		ec2Client:      ec2Client,
+		instanceID:     s.awsInstanceID,
+	}
+}
+
+func volumeCandidates(volumes []*ec2.Volume, claimTagKey string) []poolCandidate {
+	var candidates []poolCandidate
+	for _, v := range volumes {
+		c := poolCandidate{
+			availabilityZone: aws.StringValue(v.AvailabilityZone),
+			claimedBy:        tagValueOf(v.Tags, claimTagKey),
+			id:               aws.StringValue(v.VolumeId),
+			state:            aws.StringValue(v.State),
+		}
+		for _, a := range v.Attachments {
+			c.attachedTo = append(c.attachedTo, aws.StringValue(a.InstanceId))
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates
+}
+
+func (s *EBS) attach(ec2Client ec2iface.EC2API, instanceID string, volumeID string) error {
 	attachVolumeInput := &ec2.AttachVolumeInput{
 		Device:     aws.String(s.deviceName),
 		InstanceId: aws.String(instanceID),
 		VolumeId:   aws.String(volumeID),
 	}
 
-	b := backoff.NewMaxRetries(attachRequestRetries, retryInterval)
+	b := backoff.NewMaxRetries(attachRequestRetries, s.retryInterval)
 	o := func() error {
 		attachment, err := ec2Client.AttachVolume(attachVolumeInput)
-		if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "VolumeInUse" {
+			// another instance got the volume, retrying does not help
+			return backoff.Permanent(microerror.Maskf(resourceInUseError, "%s", aerr.Message()))
+		} else if err != nil {
 			return microerror.Mask(err)
 		}
 
@@ -139,15 +422,23 @@ func (s *EBS) attach(ec2Client *ec2.EC2, instanceID string, volumeID string) err
 		return microerror.Mask(err)
 	}
 
-	b = backoff.NewMaxRetries(maxRetries, retryInterval)
+	b = backoff.NewMaxRetries(maxRetries, s.retryInterval)
 	o = func() error {
-		volume, err := s.describe(ec2Client)
+		volume, err := s.describeByID(ec2Client, volumeID)
 		if err != nil {
 			return microerror.Mask(err)
 		}
 
-		if *volume.State != ec2.VolumeStateInUse && *volume.Attachments[0].InstanceId == instanceID {
-			fmt.Printf("Volume state is %q, expecting %q, retrying in %ds.\n", *volume.State, ec2.VolumeStateInUse, retryInterval/time.Second)
+		// the volume state does not tell whether this instance's attachment
+		// is done, e.g. a Multi-Attach volume is in use by the others already
+		state := ec2.VolumeAttachmentStateDetached
+		for _, a := range volume.Attachments {
+			if *a.InstanceId == instanceID {
+				state = *a.State
+			}
+		}
+		if state != ec2.VolumeAttachmentStateAttached {
+			fmt.Printf("Volume attachment state is %q, expecting %q, retrying in %ds.\n", state, ec2.VolumeAttachmentStateAttached, s.retryInterval/time.Second)
 			return microerror.Maskf(executionFailedError, "EBS not attached")
 		}
 		return nil
@@ -158,55 +449,145 @@ func (s *EBS) attach(ec2Client *ec2.EC2, instanceID string, volumeID string) err
 		return microerror.Mask(err)
 	}
 
-	fmt.Printf("Volume attached, state %q.\n", ec2.VolumeStateInUse)
+	fmt.Printf("Volume attached, attachment state %q.\n", ec2.VolumeAttachmentStateAttached)
 	return nil
 }
 
-func (s *EBS) detach(ec2Client *ec2.EC2, volume *ec2.Volume) error {
-	// wait if automatic detach happens  by terminating the instance
-	b := backoff.NewMaxRetries(waitAutoDetachMaxRetries, retryInterval)
-	o := func() error {
-		volume, err := s.describe(ec2Client)
-		if err != nil {
-			return microerror.Mask(err)
-		}
+// detach moves the volume through the detach states until it is available,
+// i.e. detached from all instances.
+// Each state waits for the volume to become available and moves on to the
+// next state on timeout.
+func (s *EBS) detach(ec2Client ec2iface.EC2API, volume *ec2.Volume) (string, error) {
+	volumeID := *volume.VolumeId
 
-		if *volume.State != ec2.VolumeStateAvailable {
-			fmt.Printf("Volume state is %q, expecting %q, retrying in %ds.\n", *volume.State, ec2.VolumeStateAvailable, retryInterval/time.Second)
-			return microerror.Maskf(executionFailedError, "EBS not detached")
+	mode := detachModeAuto
+
+	state := detachStateWaiting
+	for {
+		switch state {
+		case detachStateWaiting:
+			// wait if automatic detach happens by terminating the instance
+			detached, err := s.waitDetached(ec2Client, volumeID, waitAutoDetachMaxRetries)
+			if err != nil {
+				return "", microerror.Mask(err)
+			}
+			if detached {
+				// the volume was eventually detached by the instance by itself,
+				// no need for manual detach
+				state = detachStateDone
+			} else {
+				state = detachStateManual
+			}
+
+		case detachStateManual:
+			// volume is still attached, lets try detach it manually here
+			mode = detachModeManual
+			err := s.requestDetach(ec2Client, volumeID, false)
+			if err != nil {
+				return "", microerror.Mask(err)
+			}
+
+			var retries uint64 = maxRetries
+			if s.forceDetach {
+				retries = waitManualDetachMaxRetries
+			}
+			detached, err := s.waitDetached(ec2Client, volumeID, retries)
+			if err != nil {
+				return "", microerror.Mask(err)
+			}
+			if detached {
+				state = detachStateDone
+			} else if s.forceDetach {
+				state = detachStateForced
+			} else {
+				fmt.Printf("Failed to detach volume after %d retries.\n", retries)
+				return "", microerror.Maskf(executionFailedError, "volume %q is still attached after manual detach", volumeID)
+			}
+
+		case detachStateForced:
+			mode = detachModeForce
+			err := s.requestDetach(ec2Client, volumeID, true)
+			if err != nil {
+				return "", microerror.Mask(err)
+			}
+			s.forceDetached = true
+
+			detached, err := s.waitDetached(ec2Client, volumeID, maxRetries)
+			if err != nil {
+				return "", microerror.Mask(err)
+			}
+			if !detached {
+				fmt.Printf("Failed to force-detach volume after %d retries.\n", maxRetries)
+				return "", microerror.Maskf(executionFailedError, "volume %q is still attached after forced detach", volumeID)
+			}
+			state = detachStateDone
+
+		case detachStateDone:
+			fmt.Printf("Volume detached with mode %q, state %q .\n", mode, ec2.VolumeStateAvailable)
+			return mode, nil
 		}
-		return nil
 	}
+}
 
-	err := backoff.Retry(o, b)
+// requestDetach detaches the volume from the instance it is currently
+// attached to.
+func (s *EBS) requestDetach(ec2Client ec2iface.EC2API, volumeID string, force bool) error {
+	volume, err := s.describeByID(ec2Client, volumeID)
 	if err != nil {
-		// the Volume was eventually detached by the instance by itself, no need for manual detach
-		return nil
-	} else {
-		// volume is still attached after 10mins, lets try detach it manually here
+		return microerror.Mask(err)
+	}
+
+	// a Multi-Attach volume has to be detached from all instances, if the
+	// volume was detached in the meantime there is nothing to do
+	for _, a := range volume.Attachments {
 		detachVolumeInput := &ec2.DetachVolumeInput{
-			Device:     volume.Attachments[0].Device,
-			InstanceId: volume.Attachments[0].InstanceId,
+			Device:     a.Device,
+			InstanceId: a.InstanceId,
 			VolumeId:   volume.VolumeId,
-			Force:      aws.Bool(s.forceDetach),
+			Force:      aws.Bool(force),
 		}
 
 		detachment, err := ec2Client.DetachVolume(detachVolumeInput)
-		if strings.Contains(err.Error(), "IncorrectState") {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "IncorrectState" {
 			// volume is probably already detached, lets ignore the error
+			fmt.Printf("Volume %q is not attached to %q anymore, err: %s.\n", volumeID, *a.InstanceId, aerr.Message())
+			continue
 		} else if err != nil {
 			return microerror.Mask(err)
 		}
-		fmt.Printf("Succefully created dettach request. %q\n", detachment.String())
 
-		b = backoff.NewMaxRetries(maxRetries, retryInterval)
-		err = backoff.Retry(o, b)
+		fmt.Printf("Succefully created dettach request with force %t. %q\n", force, detachment.String())
+	}
+
+	return nil
+}
+
+// waitDetached returns true once the volume is available and false if it is
+// still attached after the given number of retries.
+func (s *EBS) waitDetached(ec2Client ec2iface.EC2API, volumeID string, retries uint64) (bool, error) {
+	var attached bool
+	b := backoff.NewMaxRetries(retries, s.retryInterval)
+	o := func() error {
+		volume, err := s.describeByID(ec2Client, volumeID)
 		if err != nil {
-			fmt.Printf("Failed to detach volume after %d retries.\n", maxRetries)
+			attached = false
 			return microerror.Mask(err)
 		}
+
+		attached = *volume.State != ec2.VolumeStateAvailable
+		if attached {
+			fmt.Printf("Volume state is %q, expecting %q, retrying in %ds.\n", *volume.State, ec2.VolumeStateAvailable, s.retryInterval/time.Second)
+			return microerror.Maskf(executionFailedError, "EBS not detached")
+		}
+		return nil
 	}
 
-	fmt.Printf("Volume detached, state %q .\n", ec2.VolumeStateAvailable)
-	return nil
+	err := backoff.Retry(o, b)
+	if attached {
+		return false, nil
+	} else if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	return true, nil
 }
@@ -0,0 +1,295 @@
+package aws
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+)
+
+// fakeEC2 simulates a single volume which is attached to other instances
+// until it is detached by one of the configured ways.
+type fakeEC2 struct {
+	ec2iface.EC2API
+
+	volumeID           string
+	attachments        []*ec2.VolumeAttachment
+	multiAttachEnabled bool
+
+	// autoDetachAfter detaches the volume after this number of describe
+	// calls, zero never detaches it by itself
+	autoDetachAfter int
+	// manualDetach and forceDetach define whether the detach requests work
+	manualDetach bool
+	forceDetach  bool
+	// detachErrCode is returned by DetachVolume if set
+	detachErrCode string
+	// attachingDescribes keeps the attachment of this instance in state
+	// attaching for this number of describe calls
+	attachingDescribes int
+
+	describeCalls  int
+	detachRequests []bool
+}
+
+func (f *fakeEC2) DescribeVolumesPages(input *ec2.DescribeVolumesInput, fn func(*ec2.DescribeVolumesOutput, bool) bool) error {
+	f.describeCalls++
+	if f.autoDetachAfter > 0 && f.describeCalls >= f.autoDetachAfter {
+		f.attachments = nil
+	}
+	for _, a := range f.attachments {
+		if *a.State == ec2.VolumeAttachmentStateAttaching {
+			if f.attachingDescribes == 0 {
+				a.State = aws.String(ec2.VolumeAttachmentStateAttached)
+			}
+			f.attachingDescribes--
+		}
+	}
+
+	state := ec2.VolumeStateAvailable
+	if len(f.attachments) > 0 {
+		state = ec2.VolumeStateInUse
+	}
+	var attachments []*ec2.VolumeAttachment
+	for _, a := range f.attachments {
+		c := *a
+		attachments = append(attachments, &c)
+	}
+
+	fn(&ec2.DescribeVolumesOutput{
+		Volumes: []*ec2.Volume{
+			{
+				Attachments:        attachments,
+				AvailabilityZone:   aws.String("eu-west-1a"),
+				MultiAttachEnabled: aws.Bool(f.multiAttachEnabled),
+				State:              aws.String(state),
+				VolumeId:           aws.String(f.volumeID),
+			},
+		},
+	}, true)
+	return nil
+}
+
+func (f *fakeEC2) DetachVolume(input *ec2.DetachVolumeInput) (*ec2.VolumeAttachment, error) {
+	force := aws.BoolValue(input.Force)
+	f.detachRequests = append(f.detachRequests, force)
+
+	if (force && f.forceDetach) || (!force && f.manualDetach) {
+		f.attachments = nil
+	}
+	if f.detachErrCode != "" {
+		return nil, awserr.New(f.detachErrCode, "volume is not attached", nil)
+	}
+	return &ec2.VolumeAttachment{}, nil
+}
+
+func (f *fakeEC2) AttachVolume(input *ec2.AttachVolumeInput) (*ec2.VolumeAttachment, error) {
+	a := &ec2.VolumeAttachment{
+		Device:     input.Device,
+		InstanceId: input.InstanceId,
+		State:      aws.String(ec2.VolumeAttachmentStateAttaching),
+		VolumeId:   input.VolumeId,
+	}
+	f.attachments = append(f.attachments, a)
+	return a, nil
+}
+
+func (f *fakeEC2) CreateTags(input *ec2.CreateTagsInput) (*ec2.CreateTagsOutput, error) {
+	return &ec2.CreateTagsOutput{}, nil
+}
+
+func newFakeEC2(attachedTo ...string) *fakeEC2 {
+	f := &fakeEC2{volumeID: "vol-1"}
+	for _, i := range attachedTo {
+		f.attachments = append(f.attachments, &ec2.VolumeAttachment{
+			Device:     aws.String("/dev/xvdh"),
+			InstanceId: aws.String(i),
+			State:      aws.String(ec2.VolumeAttachmentStateAttached),
+			VolumeId:   aws.String(f.volumeID),
+		})
+	}
+	return f
+}
+
+func newTestEBS(ec2Client ec2iface.EC2API, forceDetach bool) *EBS {
+	return &EBS{
+		awsInstanceID: "i-me",
+		deviceName:    "/dev/xvdh",
+		ec2Client:     ec2Client,
+		filters:       []*ec2.Filter{{Name: aws.String("tag:etcd"), Values: []*string{aws.String("1")}}},
+		forceDetach:   forceDetach,
+		multiAttach:   MultiAttachPolicyShare,
+	}
+}
+
+func Test_EBS_detach(t *testing.T) {
+	testCases := []struct {
+		name                   string
+		fake                   *fakeEC2
+		forceDetach            bool
+		expectedMode           string
+		expectedDetachRequests []bool
+		expectedForceDetached  bool
+		expectedErr            bool
+	}{
+		{
+			name: "case 0: waiting then done",
+			fake: func() *fakeEC2 {
+				f := newFakeEC2("i-other")
+				f.autoDetachAfter = 3
+				return f
+			}(),
+			expectedMode: detachModeAuto,
+		},
+		{
+			name: "case 1: waiting then manual then done",
+			fake: func() *fakeEC2 {
+				f := newFakeEC2("i-other")
+				f.manualDetach = true
+				return f
+			}(),
+			expectedMode:           detachModeManual,
+			expectedDetachRequests: []bool{false},
+		},
+		{
+			name:                   "case 2: manual fails without force detach",
+			fake:                   newFakeEC2("i-other"),
+			expectedDetachRequests: []bool{false},
+			expectedErr:            true,
+		},
+		{
+			name: "case 3: manual then forced then done",
+			fake: func() *fakeEC2 {
+				f := newFakeEC2("i-other")
+				f.forceDetach = true
+				return f
+			}(),
+			forceDetach:            true,
+			expectedMode:           detachModeForce,
+			expectedDetachRequests: []bool{false, true},
+			expectedForceDetached:  true,
+		},
+		{
+			name:                   "case 4: forced fails",
+			fake:                   newFakeEC2("i-other"),
+			forceDetach:            true,
+			expectedDetachRequests: []bool{false, true},
+			expectedForceDetached:  true,
+			expectedErr:            true,
+		},
+		{
+			name: "case 5: IncorrectState of a volume detached meanwhile is ignored",
+			fake: func() *fakeEC2 {
+				f := newFakeEC2("i-other")
+				f.manualDetach = true
+				f.detachErrCode = "IncorrectState"
+				return f
+			}(),
+			expectedMode:           detachModeManual,
+			expectedDetachRequests: []bool{false},
+		},
+		{
+			name: "case 6: Multi-Attach volume is detached from all instances",
+			fake: func() *fakeEC2 {
+				f := newFakeEC2("i-other", "i-third")
+				f.manualDetach = true
+				return f
+			}(),
+			expectedMode:           detachModeManual,
+			expectedDetachRequests: []bool{false, false},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestEBS(tc.fake, tc.forceDetach)
+
+			volume, err := s.describeByID(tc.fake, tc.fake.volumeID)
+			if err != nil {
+				t.Fatalf("unexpected error %#v", err)
+			}
+
+			mode, err := s.detach(tc.fake, volume)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error %#v", err)
+			}
+
+			if mode != tc.expectedMode {
+				t.Fatalf("expected mode %q, got %q", tc.expectedMode, mode)
+			}
+			if !reflect.DeepEqual(tc.fake.detachRequests, tc.expectedDetachRequests) {
+				t.Fatalf("expected detach requests with force %v, got %v", tc.expectedDetachRequests, tc.fake.detachRequests)
+			}
+			if s.ForceDetached() != tc.expectedForceDetached {
+				t.Fatalf("expected force detached %t, got %t", tc.expectedForceDetached, s.ForceDetached())
+			}
+		})
+	}
+}
+
+func Test_EBS_attach(t *testing.T) {
+	testCases := []struct {
+		name               string
+		attachedTo         []string
+		attachingDescribes int
+	}{
+		{
+			name:               "case 0: available volume",
+			attachingDescribes: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := newFakeEC2(tc.attachedTo...)
+			fake.attachingDescribes = tc.attachingDescribes
+			s := newTestEBS(fake, false)
+
+			err := s.attach(fake, s.awsInstanceID, fake.volumeID)
+			if err != nil {
+				t.Fatalf("unexpected error %#v", err)
+			}
+
+			// the wait has to last until the attachment of this instance
+			// left the attaching state
+			if fake.describeCalls != tc.attachingDescribes+1 {
+				t.Fatalf("expected %d describe calls, got %d", tc.attachingDescribes+1, fake.describeCalls)
+			}
+		})
+	}
+}
+
+func Test_EBS_AttachByTag_multiAttachShare(t *testing.T) {
+	fake := newFakeEC2("i-other")
+	fake.multiAttachEnabled = true
+	attaching := 3
+	fake.attachingDescribes = attaching
+	s := newTestEBS(fake, false)
+
+	err := s.AttachByTag()
+	if err != nil {
+		t.Fatalf("unexpected error %#v", err)
+	}
+
+	if len(fake.detachRequests) != 0 {
+		t.Fatalf("expected no detach requests, got %v", fake.detachRequests)
+	}
+	// the volume is in use by the other instance already, so only the
+	// attachment of this instance tells when the attach is done
+	for _, a := range fake.attachments {
+		if *a.InstanceId == s.awsInstanceID && *a.State != ec2.VolumeAttachmentStateAttached {
+			t.Fatalf("expected attachment state %q, got %q", ec2.VolumeAttachmentStateAttached, *a.State)
+		}
+	}
+	// one describe to find the volume, then the wait until attached
+	if fake.describeCalls != 1+attaching+1 {
+		t.Fatalf("expected %d describe calls, got %d", 1+attaching+1, fake.describeCalls)
+	}
+}
@@ -0,0 +1,29 @@
+//go:build linux
+
+package disk
+
+import (
+	"fmt"
+	"syscall"
+
+	"github.com/giantswarm/microerror"
+)
+
+// deviceNumber returns the major:minor number of a block device as used in
+// mountinfo and the udev database.
+func deviceNumber(deviceName string) (string, error) {
+	var st syscall.Stat_t
+	err := syscall.Stat(deviceName, &st)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+	return fmt.Sprintf("%d:%d", unixMajor(uint64(st.Rdev)), unixMinor(uint64(st.Rdev))), nil
+}
+
+func unixMajor(dev uint64) uint64 {
+	return ((dev >> 8) & 0xfff) | ((dev >> 32) & 0xfffff000)
+}
+
+func unixMinor(dev uint64) uint64 {
+	return (dev & 0xff) | ((dev >> 12) & 0xffffff00)
+}
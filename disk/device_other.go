@@ -0,0 +1,13 @@
+//go:build !linux
+
+package disk
+
+import (
+	"github.com/giantswarm/microerror"
+)
+
+// deviceNumber is only supported on linux, block devices are always reported
+// as unknown elsewhere.
+func deviceNumber(deviceName string) (string, error) {
+	return "", microerror.Maskf(executionFailedError, "device numbers of %q are not supported on this platform", deviceName)
+}
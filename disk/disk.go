@@ -5,44 +5,89 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"time"
 
-	"github.com/giantswarm/backoff"
 	"github.com/giantswarm/microerror"
 )
 
 const (
-	maxRetries    = 15
-	retryInterval = time.Second * 10
+	// fallback interval for checking the device when no inotify event arrives
+	statInterval = time.Second * 10
+
+	deviceReadyTimeout = time.Second * 150
+	udevReadyTimeout   = time.Second * 30
+
+	udevDataDir = "/run/udev/data"
 )
 
 var supportedFsType = []string{"ext4"}
 
+// WaitForDeviceReady blocks until the kernel registered the device and udev
+// finished processing it, so tools like lsblk see consistent data.
 func WaitForDeviceReady(deviceName string) error {
-	b := backoff.NewMaxRetries(maxRetries, retryInterval)
-	o := func() error {
-		_, err := os.Stat(deviceName)
-		if os.IsNotExist(err) {
-			fmt.Printf("Waiting until device %q is registered by kernel.\n", deviceName)
-			return err
-		}
+	fmt.Printf("Waiting until device %q is registered by kernel.\n", deviceName)
+	err := waitForFile(deviceName, deviceReadyTimeout)
+	if err != nil {
+		fmt.Printf("wait limit exceeded for device %q after %s\n", deviceName, deviceReadyTimeout)
+		return microerror.Mask(err)
+	}
+
+	err = waitForUdev(deviceName)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	fmt.Printf("Device %q is ready.\n", deviceName)
+	return nil
+}
+
+// waitForUdev waits until udev wrote its database entry for the device,
+// which happens once all rules for the device were processed.
+func waitForUdev(deviceName string) error {
+	if _, err := os.Stat(udevDataDir); os.IsNotExist(err) {
+		fmt.Printf("udev is not running, not waiting for device %q to be processed.\n", deviceName)
 		return nil
 	}
-	err := backoff.Retry(o, b)
+
+	device, err := deviceNumber(deviceName)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	dataFile := filepath.Join(udevDataDir, "b"+device)
+
+	err = waitForFile(dataFile, udevReadyTimeout)
+	if err != nil {
+		fmt.Printf("udev did not process device %q within %s\n", deviceName, udevReadyTimeout)
+		return microerror.Mask(err)
+	}
+	return nil
+}
+
+func EnsureDiskHasFileSystem(deviceName string, desiredFsType string, desiredLabel string, allowFormatNonEmpty bool) error {
+	err := ensureFileSystem(deviceName, desiredFsType, desiredLabel, allowFormatNonEmpty, true)
 	if err != nil {
-		fmt.Printf("wait limit exceeded for device %q after %d retries\n", deviceName, maxRetries)
 		return microerror.Mask(err)
 	}
 	return nil
 }
 
-func EnsureDiskHasFileSystem(deviceName string, desiredFsType string, desiredLabel string) error {
+func ensureFileSystem(deviceName string, desiredFsType string, desiredLabel string, allowFormatNonEmpty bool, scanData bool) error {
 	deviceFsType, err := getFsType(deviceName)
 	if err != nil {
 		return microerror.Mask(err)
 	}
 	if deviceFsType == "" {
+		// lsblk does not report partition tables or unknown signatures, so make
+		// sure we are not going to destroy any data before formatting
+		err = ensureDeviceIsEmpty(deviceName, scanData)
+		if IsDeviceNotEmpty(err) && allowFormatNonEmpty {
+			fmt.Printf("Device %q is not empty but formatting is allowed, continuing.\n", deviceName)
+		} else if err != nil {
+			return microerror.Mask(err)
+		}
+
 		// format disk
 		err = runMkfs(deviceName, desiredFsType, desiredLabel)
 		if err != nil {
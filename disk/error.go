@@ -2,6 +2,42 @@ package disk
 
 import "github.com/giantswarm/microerror"
 
+var deviceNotEmptyError = &microerror.Error{
+	Kind: "deviceNotEmptyError",
+}
+
+// IsDeviceNotEmpty asserts deviceNotEmptyError.
+func IsDeviceNotEmpty(err error) bool {
+	return microerror.Cause(err) == deviceNotEmptyError
+}
+
 var executionFailedError = &microerror.Error{
 	Kind: "executionFailedError",
 }
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var fileSystemCheckFailedError = &microerror.Error{
+	Kind: "fileSystemCheckFailedError",
+}
+
+// IsFileSystemCheckFailed asserts fileSystemCheckFailedError.
+func IsFileSystemCheckFailed(err error) bool {
+	return microerror.Cause(err) == fileSystemCheckFailedError
+}
+
+var etcdMembershipMismatchError = &microerror.Error{
+	Kind: "etcdMembershipMismatchError",
+}
+
+// IsEtcdMembershipMismatch asserts etcdMembershipMismatchError.
+func IsEtcdMembershipMismatch(err error) bool {
+	return microerror.Cause(err) == etcdMembershipMismatchError
+}
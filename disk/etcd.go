@@ -0,0 +1,247 @@
+package disk
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	// see go.etcd.io/etcd/server/v3/storage/wal
+	walMetadataType = 1
+
+	// protobuf wire types
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// EtcdMembership identifies the etcd member whose data is stored in a data
+// dir.
+type EtcdMembership struct {
+	ClusterID uint64
+	MemberID  uint64
+}
+
+// VerifyEtcdDataDir reads the membership from the WAL metadata in the etcd
+// data dir and compares it with the expected IDs, zero IDs are not compared.
+// A data dir without WAL belongs to a new member and passes the check, so the
+// data dir must be on the file-system of deviceName, otherwise e.g. an
+// unmounted volume would pass as new member.
+func VerifyEtcdDataDir(dataDir string, deviceName string, expected EtcdMembership) error {
+	onDevice, err := IsOnDevice(dataDir, deviceName)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if !onDevice {
+		return microerror.Maskf(executionFailedError, "etcd data dir %q is not on the file-system of device %q", dataDir, deviceName)
+	}
+
+	membership, found, err := ReadEtcdMembership(dataDir)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if !found {
+		fmt.Printf("Etcd data dir %q has no WAL, assuming a new member.\n", dataDir)
+		return nil
+	}
+
+	fmt.Printf("Etcd data dir %q belongs to member %x of cluster %x.\n", dataDir, membership.MemberID, membership.ClusterID)
+
+	if expected.MemberID != 0 && membership.MemberID != expected.MemberID {
+		return microerror.Maskf(etcdMembershipMismatchError, "etcd data dir %q belongs to member %x but expected member %x", dataDir, membership.MemberID, expected.MemberID)
+	}
+	if expected.ClusterID != 0 && membership.ClusterID != expected.ClusterID {
+		return microerror.Maskf(etcdMembershipMismatchError, "etcd data dir %q belongs to cluster %x but expected cluster %x", dataDir, membership.ClusterID, expected.ClusterID)
+	}
+
+	return nil
+}
+
+// ReadEtcdMembership returns the member and cluster ID stored in the metadata
+// record of the first WAL file in dataDir/member/wal.
+func ReadEtcdMembership(dataDir string) (EtcdMembership, bool, error) {
+	walDir := filepath.Join(dataDir, "member", "wal")
+
+	walFiles, err := filepath.Glob(filepath.Join(walDir, "*.wal"))
+	if err != nil {
+		return EtcdMembership{}, false, microerror.Mask(err)
+	}
+	if len(walFiles) == 0 {
+		return EtcdMembership{}, false, nil
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "member", "snap")); err != nil {
+		return EtcdMembership{}, false, microerror.Maskf(executionFailedError, "etcd data dir %q has a WAL but no snap dir", dataDir)
+	}
+
+	// WAL file names are <seq>-<index>.wal in hex, so sorting them by name
+	// sorts them by sequence, every file starts with the metadata record
+	sort.Strings(walFiles)
+
+	metadata, err := readWALMetadata(walFiles[0])
+	if err != nil {
+		return EtcdMembership{}, false, microerror.Mask(err)
+	}
+
+	membership, err := parseEtcdMetadata(metadata)
+	if err != nil {
+		return EtcdMembership{}, false, microerror.Mask(err)
+	}
+
+	return membership, true, nil
+}
+
+// readWALMetadata returns the data of the first metadata record. Records are
+// framed by a little-endian int64, the lower 56 bits hold the record length
+// and, if the highest bit is set, bits 56-58 the number of padding bytes.
+func readWALMetadata(fileName string) ([]byte, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	// the frame length is read from the file, it must not exceed the rest of
+	// the file before the record is allocated
+	remaining := uint64(fi.Size())
+
+	r := bufio.NewReader(f)
+	for {
+		var frame int64
+		err = binary.Read(r, binary.LittleEndian, &frame)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		if frame == 0 {
+			// preallocated space after the last record
+			break
+		}
+		remaining -= 8
+
+		length := uint64(frame) & ^(uint64(0xff) << 56)
+		var padding uint64
+		if frame < 0 {
+			padding = (uint64(frame) >> 56) & 0x7
+		}
+
+		if length+padding > remaining {
+			return nil, microerror.Maskf(executionFailedError, "WAL %q is truncated, record of %d bytes exceeds the remaining %d bytes", fileName, length+padding, remaining)
+		}
+		remaining -= length + padding
+
+		record := make([]byte, length+padding)
+		_, err = io.ReadFull(r, record)
+		if err != nil {
+			return nil, microerror.Maskf(executionFailedError, "WAL %q is truncated, err: %s", fileName, err)
+		}
+
+		recordType, data, err := parseWALRecord(record[:length])
+		if err != nil {
+			return nil, microerror.Maskf(executionFailedError, "WAL %q is corrupted, err: %s", fileName, err)
+		}
+		if recordType == walMetadataType {
+			return data, nil
+		}
+	}
+
+	return nil, microerror.Maskf(executionFailedError, "WAL %q has no metadata record", fileName)
+}
+
+// parseWALRecord decodes walpb.Record, type is field 1 and data field 3.
+func parseWALRecord(b []byte) (int64, []byte, error) {
+	var recordType int64
+	var data []byte
+
+	err := parseProto(b, func(field uint64, value uint64, bytes []byte) {
+		switch field {
+		case 1:
+			recordType = int64(value)
+		case 3:
+			data = bytes
+		}
+	})
+	if err != nil {
+		return 0, nil, microerror.Mask(err)
+	}
+
+	return recordType, data, nil
+}
+
+// parseEtcdMetadata decodes etcdserverpb.Metadata, NodeID is field 1 and
+// ClusterID field 2.
+func parseEtcdMetadata(b []byte) (EtcdMembership, error) {
+	var m EtcdMembership
+
+	err := parseProto(b, func(field uint64, value uint64, bytes []byte) {
+		switch field {
+		case 1:
+			m.MemberID = value
+		case 2:
+			m.ClusterID = value
+		}
+	})
+	if err != nil {
+		return EtcdMembership{}, microerror.Mask(err)
+	}
+
+	return m, nil
+}
+
+// parseProto walks the fields of a protobuf message, calling fn with the
+// value of varint fields or the content of length delimited fields. Fixed
+// size fields are skipped.
+func parseProto(b []byte, fn func(field uint64, value uint64, bytes []byte)) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return microerror.Maskf(executionFailedError, "invalid protobuf key")
+		}
+		b = b[n:]
+
+		field := key >> 3
+		switch key & 0x7 {
+		case wireVarint:
+			value, n := binary.Uvarint(b)
+			if n <= 0 {
+				return microerror.Maskf(executionFailedError, "invalid protobuf varint of field %d", field)
+			}
+			b = b[n:]
+			fn(field, value, nil)
+		case wireBytes:
+			length, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < length {
+				return microerror.Maskf(executionFailedError, "invalid protobuf length of field %d", field)
+			}
+			b = b[n:]
+			fn(field, 0, b[:length])
+			b = b[length:]
+		case wireFixed64:
+			if len(b) < 8 {
+				return microerror.Maskf(executionFailedError, "invalid protobuf fixed64 of field %d", field)
+			}
+			b = b[8:]
+		case wireFixed32:
+			if len(b) < 4 {
+				return microerror.Maskf(executionFailedError, "invalid protobuf fixed32 of field %d", field)
+			}
+			b = b[4:]
+		default:
+			return microerror.Maskf(executionFailedError, "unsupported protobuf wire type %d of field %d", key&0x7, field)
+		}
+	}
+
+	return nil
+}
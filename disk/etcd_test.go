@@ -0,0 +1,126 @@
+package disk
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// walRecord encodes a walpb.Record of the given type and data with the frame
+// etcd writes, padded to 8 bytes.
+func walRecord(recordType uint64, data []byte) []byte {
+	record := []byte{0x08}
+	record = appendUvarint(record, recordType)
+	record = append(record, 0x1a)
+	record = appendUvarint(record, uint64(len(data)))
+	record = append(record, data...)
+
+	frame := uint64(len(record))
+	padding := (8 - len(record)%8) % 8
+	if padding > 0 {
+		frame |= uint64(0x80|padding) << 56
+	}
+
+	b := walFrame(frame)
+	b = append(b, record...)
+	return append(b, make([]byte, padding)...)
+}
+
+func walFrame(frame uint64) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, frame)
+	return b
+}
+
+// etcdMetadata encodes an etcdserverpb.Metadata.
+func etcdMetadata(memberID uint64, clusterID uint64) []byte {
+	b := []byte{0x08}
+	b = appendUvarint(b, memberID)
+	b = append(b, 0x10)
+	return appendUvarint(b, clusterID)
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, v)
+	return append(b, buf[:n]...)
+}
+
+func Test_ReadEtcdMembership(t *testing.T) {
+	// the first record of a WAL is the CRC record, type 4
+	valid := append(walRecord(4, nil), walRecord(walMetadataType, etcdMetadata(0x8e9e05c52164694d, 0xcdf818194e3a8c32))...)
+	// preallocated space follows the last record
+	valid = append(valid, make([]byte, 64)...)
+
+	testCases := []struct {
+		name               string
+		wal                []byte
+		noWAL              bool
+		expectedMembership EtcdMembership
+		expectedFound      bool
+		expectedErr        bool
+	}{
+		{
+			name:               "case 0: valid WAL",
+			wal:                valid,
+			expectedMembership: EtcdMembership{ClusterID: 0xcdf818194e3a8c32, MemberID: 0x8e9e05c52164694d},
+			expectedFound:      true,
+		},
+		{
+			name:  "case 1: data dir without WAL",
+			noWAL: true,
+		},
+		{
+			name:        "case 2: empty WAL file",
+			wal:         []byte{},
+			expectedErr: true,
+		},
+		{
+			name:        "case 3: truncated frame",
+			wal:         walRecord(walMetadataType, etcdMetadata(1, 2))[:12],
+			expectedErr: true,
+		},
+		{
+			name:        "case 4: frame length exceeding the file",
+			wal:         append(walFrame(0x00ffffffffffff00), make([]byte, 16)...),
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dataDir := t.TempDir()
+			for _, d := range []string{"snap", "wal"} {
+				err := os.MkdirAll(filepath.Join(dataDir, "member", d), 0755)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !tc.noWAL {
+				err := ioutil.WriteFile(filepath.Join(dataDir, "member", "wal", "0000000000000000-0000000000000000.wal"), tc.wal, 0600)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			membership, found, err := ReadEtcdMembership(dataDir)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %#v", err)
+			}
+			if found != tc.expectedFound {
+				t.Fatalf("expected found %t, got %t", tc.expectedFound, found)
+			}
+			if membership != tc.expectedMembership {
+				t.Fatalf("expected membership %+v, got %+v", tc.expectedMembership, membership)
+			}
+		})
+	}
+}
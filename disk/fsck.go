@@ -0,0 +1,88 @@
+package disk
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+
+	"github.com/giantswarm/microerror"
+)
+
+// CheckFileSystem runs a file-system check on the existing file-system of the
+// device. Devices without file-system are skipped as there is nothing to
+// check yet.
+func CheckFileSystem(deviceName string) error {
+	fsType, err := getFsType(deviceName)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if fsType == "" {
+		fmt.Printf("Device %q has no file-system, skipping file-system check.\n", deviceName)
+		return nil
+	}
+
+	mounted, err := isDeviceMounted(deviceName)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if mounted {
+		fmt.Printf("Device %q is mounted, skipping file-system check.\n", deviceName)
+		return nil
+	}
+
+	var cmd *exec.Cmd
+	switch fsType {
+	case "ext2", "ext3", "ext4":
+		cmd = exec.Command("/sbin/e2fsck", "-p", deviceName)
+	case "xfs":
+		cmd = exec.Command("/sbin/xfs_repair", "-n", deviceName)
+	default:
+		return microerror.Maskf(executionFailedError, fmt.Sprintf("file-system check for fsType %q is not supported", fsType))
+	}
+
+	fmt.Printf("Checking file-system %q on device %q.\n", fsType, deviceName)
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err = cmd.Run()
+	exitCode := 0
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		exitCode = exitErr.ExitCode()
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+	fmt.Printf("%s", out.String())
+
+	switch {
+	case exitCode == 0:
+		fmt.Printf("File-system on device %q is clean.\n", deviceName)
+	case fsType != "xfs" && exitCode < 4:
+		// e2fsck exit code 1 and 2 mean errors were found and corrected
+		fmt.Printf("File-system errors on device %q were corrected, exit code %d.\n", deviceName, exitCode)
+	default:
+		return microerror.Maskf(fileSystemCheckFailedError, "file-system check on device %q failed with exit code %d", deviceName, exitCode)
+	}
+
+	return nil
+}
+
+// isDeviceMounted checks whether the device is mounted anywhere.
+func isDeviceMounted(deviceName string) (bool, error) {
+	device, err := deviceNumber(deviceName)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	devices, err := getMountedDevices()
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+	for _, d := range devices {
+		if d == device {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,180 @@
+package disk
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	mapperDir = "/dev/mapper"
+)
+
+type LUKSConfig struct {
+	AllowFormatNonEmpty bool
+	DeviceName          string
+	Key                 []byte
+	MapperName          string
+}
+
+type LUKS struct {
+	allowFormatNonEmpty bool
+	deviceName          string
+	key                 []byte
+	mapperName          string
+}
+
+func NewLUKS(config LUKSConfig) (*LUKS, error) {
+	if config.DeviceName == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.DeviceName must not be empty")
+	}
+	if len(config.Key) == 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.Key must not be empty")
+	}
+	if config.MapperName == "" || strings.Contains(config.MapperName, "/") {
+		return nil, microerror.Maskf(invalidConfigError, "config.MapperName must be a non empty name without slashes")
+	}
+
+	newLUKS := &LUKS{
+		allowFormatNonEmpty: config.AllowFormatNonEmpty,
+		deviceName:          config.DeviceName,
+		key:                 config.Key,
+		mapperName:          config.MapperName,
+	}
+	return newLUKS, nil
+}
+
+// MapperDevice returns the path of the opened LUKS container, this is the
+// device which carries the file-system.
+func (l *LUKS) MapperDevice() string {
+	return filepath.Join(mapperDir, l.mapperName)
+}
+
+// EnsureOpened initialises a LUKS2 container on the device if it is empty and
+// opens it using the configured key.
+func (l *LUKS) EnsureOpened() error {
+	isLUKS, err := l.isLUKS()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if !isLUKS {
+		// never put a LUKS header over existing data
+		err = ensureDeviceIsEmpty(l.deviceName, true)
+		if IsDeviceNotEmpty(err) && l.allowFormatNonEmpty {
+			fmt.Printf("Device %q is not empty but formatting is allowed, continuing.\n", l.deviceName)
+		} else if err != nil {
+			return microerror.Mask(err)
+		}
+
+		err = l.runCryptsetup("luksFormat", "--type", "luks2", "--batch-mode", "--key-file", "-", l.deviceName)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		fmt.Printf("Device %q was initialised as LUKS2 container.\n", l.deviceName)
+	} else {
+		fmt.Printf("Device %q has already a LUKS container.\n", l.deviceName)
+	}
+
+	if _, err := os.Stat(l.MapperDevice()); err == nil {
+		// a stale mapper of another device must never be formatted or mounted
+		// in place of the attached volume
+		backingDevice, err := l.backingDevice()
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		same, err := sameDevice(backingDevice, l.deviceName)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		if !same {
+			return microerror.Maskf(executionFailedError, "LUKS container %q is opened on %q but expected %q", l.MapperDevice(), backingDevice, l.deviceName)
+		}
+
+		fmt.Printf("LUKS container %q is already opened.\n", l.MapperDevice())
+		return nil
+	}
+
+	err = l.runCryptsetup("open", "--type", "luks2", "--key-file", "-", l.deviceName, l.mapperName)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	fmt.Printf("LUKS container on %q was opened as %q.\n", l.deviceName, l.MapperDevice())
+
+	return nil
+}
+
+// EnsureHasFileSystem formats the opened LUKS container if it has no
+// file-system yet. The decrypted content of a fresh container is random, so
+// only known signatures are considered when checking it is empty.
+func (l *LUKS) EnsureHasFileSystem(desiredFsType string, desiredLabel string) error {
+	err := ensureFileSystem(l.MapperDevice(), desiredFsType, desiredLabel, l.allowFormatNonEmpty, false)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	return nil
+}
+
+// backingDevice returns the device of the opened LUKS container as reported
+// by cryptsetup status.
+func (l *LUKS) backingDevice() (string, error) {
+	var out, outError bytes.Buffer
+	cmd := exec.Command("/sbin/cryptsetup", "status", l.mapperName)
+	cmd.Stdout = &out
+	cmd.Stderr = &outError
+	err := cmd.Run()
+	if err != nil {
+		return "", microerror.Maskf(executionFailedError, fmt.Sprintf("cryptsetup status failed for '%s', err: %s", l.mapperName, outError.String()))
+	}
+
+	for _, line := range strings.Split(out.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "device:" {
+			return fields[1], nil
+		}
+	}
+
+	return "", microerror.Maskf(executionFailedError, "cryptsetup status reported no device for '%s'", l.mapperName)
+}
+
+// sameDevice compares device paths after resolving symlinks, e.g. /dev/xvdh
+// pointing to /dev/nvme1n1 on nitro instances.
+func sameDevice(a string, b string) (bool, error) {
+	resolvedA, err := filepath.EvalSymlinks(a)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+	resolvedB, err := filepath.EvalSymlinks(b)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+	return resolvedA == resolvedB, nil
+}
+
+func (l *LUKS) isLUKS() (bool, error) {
+	cmd := exec.Command("/sbin/cryptsetup", "isLuks", l.deviceName)
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		return false, nil
+	} else if err != nil {
+		return false, microerror.Mask(err)
+	}
+	return true, nil
+}
+
+func (l *LUKS) runCryptsetup(args ...string) error {
+	var outError bytes.Buffer
+	cmd := exec.Command("/sbin/cryptsetup", args...)
+	cmd.Stdin = bytes.NewReader(l.key)
+	cmd.Stderr = &outError
+	err := cmd.Run()
+	if err != nil {
+		return microerror.Maskf(executionFailedError, fmt.Sprintf("cryptsetup %s failed for '%s', err: %s", args[0], l.deviceName, outError.String()))
+	}
+	return nil
+}
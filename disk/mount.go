@@ -0,0 +1,415 @@
+package disk
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	MountWhatLabel = "label"
+	MountWhatUUID  = "uuid"
+
+	mountInfoFile = "/proc/self/mountinfo"
+	mountUnitWant = "local-fs.target"
+	systemctl     = "systemctl"
+	// systemdRunDir exists if the host is booted with systemd, in a container
+	// it is only present if the host's /run/systemd is mounted
+	systemdRunDir = "/run/systemd/system"
+)
+
+const mountUnitTemplate = `# generated by aws-attach-etcd-dep, do not edit
+[Unit]
+Description=Mount {{.What}} to {{.Where}}
+
+[Mount]
+What={{.What}}
+Where={{.Where}}
+Type={{.FsType}}
+Options={{.Options}}
+
+[Install]
+WantedBy=` + mountUnitWant + `
+`
+
+type MountConfig struct {
+	DeviceName string
+	FsType     string
+	MountNow   bool
+	Options    string
+	Path       string
+	UnitDir    string
+	What       string
+}
+
+type Mount struct {
+	deviceName string
+	fsType     string
+	mountNow   bool
+	options    string
+	path       string
+	unitDir    string
+	what       string
+}
+
+type mountUnitParams struct {
+	FsType  string
+	Options string
+	What    string
+	Where   string
+}
+
+func NewMount(config MountConfig) (*Mount, error) {
+	if config.DeviceName == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.DeviceName must not be empty")
+	}
+	if config.FsType == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.FsType must not be empty")
+	}
+	if config.Options == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.Options must not be empty")
+	}
+	if !filepath.IsAbs(config.Path) {
+		return nil, microerror.Maskf(invalidConfigError, "config.Path must be an absolute path")
+	}
+	if config.UnitDir == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.UnitDir must not be empty")
+	}
+	if config.What != MountWhatLabel && config.What != MountWhatUUID {
+		return nil, microerror.Maskf(invalidConfigError, "config.What must be one of %q or %q", MountWhatLabel, MountWhatUUID)
+	}
+
+	newMount := &Mount{
+		deviceName: config.DeviceName,
+		fsType:     config.FsType,
+		mountNow:   config.MountNow,
+		options:    config.Options,
+		path:       filepath.Clean(config.Path),
+		unitDir:    config.UnitDir,
+		what:       config.What,
+	}
+	return newMount, nil
+}
+
+// EnsureMounted writes and enables the systemd mount unit for the device and,
+// if configured, starts it right away. If systemd is not reachable, e.g. in a
+// container without access to the host's systemd, the unit is only written
+// and the device is mounted directly.
+func (m *Mount) EnsureMounted() error {
+	err := os.MkdirAll(m.path, 0755) // nolint
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	unitFile, err := m.writeUnit()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	systemd := systemdReachable()
+	if systemd {
+		// systemd only picks up the new or changed unit after a reload
+		err = runSystemctl("daemon-reload")
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		err = runSystemctl("enable", unitFile)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		fmt.Printf("Enabled mount unit %q.\n", unitFile)
+	} else {
+		fmt.Printf("systemd is not reachable, mount unit %q is not enabled.\n", unitFile)
+	}
+
+	if !m.mountNow {
+		return nil
+	}
+
+	mounted, err := m.verifyMountedDevice()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if mounted {
+		fmt.Printf("Device %q is already mounted to %q.\n", m.deviceName, m.path)
+		return nil
+	}
+
+	if systemd {
+		err = runSystemctl("start", mountUnitName(m.path))
+	} else {
+		err = mountDevice(m.deviceName, m.path, m.fsType, m.options)
+	}
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	mounted, err = m.verifyMountedDevice()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if !mounted {
+		return microerror.Maskf(executionFailedError, "device %q is not mounted to %q after mount", m.deviceName, m.path)
+	}
+
+	fmt.Printf("Device %q was mounted to %q.\n", m.deviceName, m.path)
+	return nil
+}
+
+func (m *Mount) writeUnit() (string, error) {
+	what, err := m.unitWhat()
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	p := mountUnitParams{
+		FsType:  m.fsType,
+		Options: m.options,
+		What:    what,
+		Where:   m.path,
+	}
+
+	var buff bytes.Buffer
+	t := template.Must(template.New("mount").Parse(mountUnitTemplate))
+
+	err = t.Execute(&buff, p)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	unitFile := filepath.Join(m.unitDir, mountUnitName(m.path))
+
+	err = ioutil.WriteFile(unitFile, buff.Bytes(), 0644) // nolint
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	fmt.Printf("Wrote mount unit %q with What=%s.\n", unitFile, what)
+	return unitFile, nil
+}
+
+// unitWhat references the device by the label or UUID of the file-system
+// actually on it, an existing file-system might not carry the label the tool
+// would have formatted it with.
+func (m *Mount) unitWhat() (string, error) {
+	if m.what == MountWhatLabel {
+		label, err := getBlockDeviceProperty(m.deviceName, "LABEL")
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+		if label == "" {
+			return "", microerror.Maskf(executionFailedError, "device %q has no file-system label", m.deviceName)
+		}
+		return filepath.Join("/dev/disk/by-label", label), nil
+	}
+
+	uuid, err := getBlockDeviceProperty(m.deviceName, "UUID")
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+	if uuid == "" {
+		return "", microerror.Maskf(executionFailedError, "device %q has no file-system UUID", m.deviceName)
+	}
+
+	return filepath.Join("/dev/disk/by-uuid", uuid), nil
+}
+
+// verifyMountedDevice checks whether something is mounted to the mount path
+// and if so, that it is the expected device.
+func (m *Mount) verifyMountedDevice() (bool, error) {
+	expected, err := deviceNumber(m.deviceName)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	mounted, err := getMountedDevice(m.path)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+	if mounted == "" {
+		return false, nil
+	}
+	if mounted != expected {
+		return false, microerror.Maskf(executionFailedError, "%q has device %s mounted but attached device %q is %s", m.path, mounted, m.deviceName, expected)
+	}
+
+	return true, nil
+}
+
+type mountInfo struct {
+	device string
+	path   string
+}
+
+// getMountedDevice returns the major:minor of the device mounted to path or
+// an empty string if nothing is mounted there.
+func getMountedDevice(path string) (string, error) {
+	mounts, err := readMountInfo()
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	var device string
+	for _, m := range mounts {
+		if m.path == path {
+			// the last matching entry is the one on top
+			device = m.device
+		}
+	}
+
+	return device, nil
+}
+
+// IsOnDevice checks whether path lies on the file-system of deviceName
+// according to mountinfo, i.e. the innermost mount containing path is a mount
+// of the device. The path does not have to exist.
+func IsOnDevice(path string, deviceName string) (bool, error) {
+	expected, err := deviceNumber(deviceName)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	mounts, err := readMountInfo()
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	path = filepath.Clean(path)
+
+	var device string
+	var innermost string
+	for _, m := range mounts {
+		if !isSubPath(m.path, path) || len(m.path) < len(innermost) {
+			continue
+		}
+		// the last matching entry is the one on top
+		device = m.device
+		innermost = m.path
+	}
+
+	return device == expected, nil
+}
+
+func isSubPath(parent string, path string) bool {
+	return parent == "/" || path == parent || strings.HasPrefix(path, parent+"/")
+}
+
+// getMountedDevices returns the major:minor of all mounted devices.
+func getMountedDevices() ([]string, error) {
+	mounts, err := readMountInfo()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var devices []string
+	for _, m := range mounts {
+		devices = append(devices, m.device)
+	}
+
+	return devices, nil
+}
+
+func readMountInfo() ([]mountInfo, error) {
+	f, err := os.Open(mountInfoFile)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	defer f.Close()
+
+	var mounts []mountInfo
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		mounts = append(mounts, mountInfo{
+			device: fields[2],
+			path:   unescapeMountPath(fields[4]),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return mounts, nil
+}
+
+func getBlockDeviceProperty(deviceName string, property string) (string, error) {
+	var out, outError bytes.Buffer
+	cmd := exec.Command("/bin/lsblk", "-n", "-o", property, deviceName)
+	cmd.Stdout = &out
+	cmd.Stderr = &outError
+	err := cmd.Run()
+	if err != nil {
+		return "", microerror.Maskf(executionFailedError, fmt.Sprintf("failed to get %s for '%s', err: %s", strings.ToLower(property), deviceName, outError.String()))
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// systemdReachable checks whether systemctl is installed and the host is
+// running systemd.
+func systemdReachable() bool {
+	if _, err := exec.LookPath(systemctl); err != nil {
+		return false
+	}
+	if _, err := os.Stat(systemdRunDir); err != nil {
+		return false
+	}
+	return true
+}
+
+func runSystemctl(args ...string) error {
+	var outError bytes.Buffer
+	cmd := exec.Command(systemctl, args...)
+	cmd.Stderr = &outError
+	err := cmd.Run()
+	if err != nil {
+		return microerror.Maskf(executionFailedError, fmt.Sprintf("systemctl %s failed, err: %s", strings.Join(args, " "), outError.String()))
+	}
+	return nil
+}
+
+// mountUnitName returns the unit name systemd expects for a mount point,
+// see systemd-escape --path --suffix=mount.
+func mountUnitName(path string) string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return "-.mount"
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		switch {
+		case c == '/':
+			b.WriteByte('-')
+		case c == '.' && i == 0:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == ':', c == '_', c == '.':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		}
+	}
+
+	return b.String() + ".mount"
+}
+
+// unescapeMountPath decodes the octal escapes used in mountinfo.
+func unescapeMountPath(path string) string {
+	r := strings.NewReplacer(`\040`, " ", `\011`, "\t", `\012`, "\n", `\134`, `\`)
+	return r.Replace(path)
+}
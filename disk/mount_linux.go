@@ -0,0 +1,77 @@
+//go:build linux
+
+package disk
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+
+	"github.com/giantswarm/microerror"
+)
+
+// mountFlags maps the mount options handled by the kernel as flags, all
+// other options are passed to the file-system as data.
+var mountFlags = map[string]struct {
+	clear bool
+	flag  uintptr
+}{
+	"async":         {clear: true, flag: syscall.MS_SYNCHRONOUS},
+	"atime":         {clear: true, flag: syscall.MS_NOATIME},
+	"defaults":      {},
+	"dev":           {clear: true, flag: syscall.MS_NODEV},
+	"diratime":      {clear: true, flag: syscall.MS_NODIRATIME},
+	"dirsync":       {flag: syscall.MS_DIRSYNC},
+	"exec":          {clear: true, flag: syscall.MS_NOEXEC},
+	"noatime":       {flag: syscall.MS_NOATIME},
+	"nodev":         {flag: syscall.MS_NODEV},
+	"nodiratime":    {flag: syscall.MS_NODIRATIME},
+	"noexec":        {flag: syscall.MS_NOEXEC},
+	"norelatime":    {clear: true, flag: syscall.MS_RELATIME},
+	"nostrictatime": {clear: true, flag: syscall.MS_STRICTATIME},
+	"nosuid":        {flag: syscall.MS_NOSUID},
+	"relatime":      {flag: syscall.MS_RELATIME},
+	"ro":            {flag: syscall.MS_RDONLY},
+	"rw":            {clear: true, flag: syscall.MS_RDONLY},
+	"strictatime":   {flag: syscall.MS_STRICTATIME},
+	"suid":          {clear: true, flag: syscall.MS_NOSUID},
+	"sync":          {flag: syscall.MS_SYNCHRONOUS},
+}
+
+// mountDevice mounts the device with mount(2), used if systemd is not
+// reachable.
+func mountDevice(deviceName string, path string, fsType string, options string) error {
+	flags, data := parseMountOptions(options)
+
+	err := syscall.Mount(deviceName, path, fsType, flags, data)
+	if err != nil {
+		return microerror.Maskf(executionFailedError, "mounting %q to %q failed, err: %s", deviceName, path, err)
+	}
+
+	fmt.Printf("Mounted device %q to %q directly.\n", deviceName, path)
+	return nil
+}
+
+// parseMountOptions splits fstab style mount options into mount flags and
+// file-system specific data. Options only known to mount(8) like nofail are
+// dropped.
+func parseMountOptions(options string) (uintptr, string) {
+	var flags uintptr
+	var data []string
+	for _, o := range strings.Split(options, ",") {
+		if o == "" || o == "auto" || o == "noauto" || o == "nofail" || o == "user" || o == "nouser" || strings.HasPrefix(o, "x-") {
+			continue
+		}
+		f, ok := mountFlags[o]
+		if !ok {
+			data = append(data, o)
+			continue
+		}
+		if f.clear {
+			flags &^= f.flag
+		} else {
+			flags |= f.flag
+		}
+	}
+	return flags, strings.Join(data, ",")
+}
@@ -0,0 +1,50 @@
+//go:build linux
+
+package disk
+
+import (
+	"syscall"
+	"testing"
+)
+
+func Test_parseMountOptions(t *testing.T) {
+	testCases := []struct {
+		name          string
+		options       string
+		expectedFlags uintptr
+		expectedData  string
+	}{
+		{
+			name:    "case 0: defaults",
+			options: "defaults",
+		},
+		{
+			name:          "case 1: flags and file-system data",
+			options:       "noatime,nodev,nosuid,discard,commit=30",
+			expectedFlags: syscall.MS_NOATIME | syscall.MS_NODEV | syscall.MS_NOSUID,
+			expectedData:  "discard,commit=30",
+		},
+		{
+			name:          "case 2: later options override earlier ones",
+			options:       "ro,rw,noexec",
+			expectedFlags: syscall.MS_NOEXEC,
+		},
+		{
+			name:          "case 3: options only known to mount(8) are dropped",
+			options:       "defaults,nofail,x-systemd.device-timeout=10s,ro",
+			expectedFlags: syscall.MS_RDONLY,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			flags, data := parseMountOptions(tc.options)
+			if flags != tc.expectedFlags {
+				t.Fatalf("expected flags %#x, got %#x", tc.expectedFlags, flags)
+			}
+			if data != tc.expectedData {
+				t.Fatalf("expected data %q, got %q", tc.expectedData, data)
+			}
+		})
+	}
+}
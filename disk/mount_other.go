@@ -0,0 +1,11 @@
+//go:build !linux
+
+package disk
+
+import (
+	"github.com/giantswarm/microerror"
+)
+
+func mountDevice(deviceName string, path string, fsType string, options string) error {
+	return microerror.Maskf(executionFailedError, "mounting %q without systemd is only supported on linux", deviceName)
+}
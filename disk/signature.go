@@ -0,0 +1,115 @@
+package disk
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	// size of the area at the beginning and at the end of the device that
+	// must be zeroed before we consider the device empty
+	scanSize = 1024 * 1024
+)
+
+// ensureDeviceIsEmpty returns an error if the device carries any signature
+// known to wipefs (partition tables, LUKS headers, RAID members, ...) or, if
+// scanData is set, any non-zero data in its first or last MiB.
+func ensureDeviceIsEmpty(deviceName string, scanData bool) error {
+	var findings []string
+
+	signatures, err := getSignatures(deviceName)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	findings = append(findings, signatures...)
+
+	if scanData {
+		nonZero, err := findNonZeroData(deviceName)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		findings = append(findings, nonZero...)
+	}
+
+	if len(findings) > 0 {
+		for _, f := range findings {
+			fmt.Printf("Device %q is not empty: %s.\n", deviceName, f)
+		}
+		return microerror.Maskf(deviceNotEmptyError, "device %q is not empty, found %d signature(s) or data region(s)", deviceName, len(findings))
+	}
+
+	return nil
+}
+
+func getSignatures(deviceName string) ([]string, error) {
+	var out, outError bytes.Buffer
+	cmd := exec.Command("/sbin/wipefs", "--no-act", "--parsable", "--noheadings", deviceName)
+	cmd.Stdout = &out
+	cmd.Stderr = &outError
+	err := cmd.Run()
+	if err != nil {
+		return nil, microerror.Maskf(executionFailedError, fmt.Sprintf("failed to list signatures for '%s', err: %s", deviceName, outError.String()))
+	}
+
+	var signatures []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		// wipefs parsable output: offset,uuid,label,type
+		fields := strings.Split(line, ",")
+		if len(fields) < 4 {
+			signatures = append(signatures, fmt.Sprintf("signature %q", line))
+			continue
+		}
+		signatures = append(signatures, fmt.Sprintf("signature %q at offset %s", fields[3], fields[0]))
+	}
+
+	return signatures, nil
+}
+
+func findNonZeroData(deviceName string) ([]string, error) {
+	f, err := os.Open(deviceName)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	defer f.Close()
+
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	offsets := []int64{0}
+	if size > scanSize {
+		last := size - scanSize
+		if last < scanSize {
+			// the regions overlap, scan the remainder only
+			last = scanSize
+		}
+		offsets = append(offsets, last)
+	}
+
+	var findings []string
+	buf := make([]byte, scanSize)
+	for _, offset := range offsets {
+		n, err := f.ReadAt(buf, offset)
+		if err != nil && err != io.EOF {
+			return nil, microerror.Mask(err)
+		}
+		for i := 0; i < n; i++ {
+			if buf[i] != 0 {
+				findings = append(findings, fmt.Sprintf("non-zero data at offset %d", offset+int64(i)))
+				break
+			}
+		}
+	}
+
+	return findings, nil
+}
@@ -0,0 +1,70 @@
+//go:build linux
+
+package disk
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	inotifyMask = syscall.IN_CREATE | syscall.IN_MOVED_TO | syscall.IN_ATTRIB
+)
+
+// waitForFile blocks until path exists or the timeout is reached. It watches
+// the parent directory with inotify and falls back to os.Stat every
+// statInterval in case the directory cannot be watched or an event is missed.
+func waitForFile(path string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	var watcher *os.File
+	{
+		fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+		if err == nil {
+			_, err = syscall.InotifyAddWatch(fd, filepath.Dir(path), inotifyMask)
+			if err != nil {
+				syscall.Close(fd)
+			} else {
+				watcher = os.NewFile(uintptr(fd), "inotify")
+				defer watcher.Close()
+			}
+		}
+	}
+
+	buf := make([]byte, syscall.SizeofInotifyEvent+syscall.NAME_MAX+1)
+	for {
+		// stat after the watch was added so a file created in between is not missed
+		if _, err := os.Stat(path); err == nil {
+			return nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return microerror.Maskf(executionFailedError, "%q did not appear within %s", path, timeout)
+		}
+		wait := statInterval
+		if remaining < wait {
+			wait = remaining
+		}
+
+		if watcher == nil {
+			time.Sleep(wait)
+			continue
+		}
+
+		err := watcher.SetReadDeadline(time.Now().Add(wait))
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		// the content of the events does not matter, any change in the
+		// directory triggers another stat
+		_, err = watcher.Read(buf)
+		if err != nil && !os.IsTimeout(err) {
+			return microerror.Mask(err)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+//go:build !linux
+
+package disk
+
+import (
+	"os"
+	"time"
+
+	"github.com/giantswarm/microerror"
+)
+
+// waitForFile blocks until path exists or the timeout is reached, polling
+// with os.Stat every statInterval.
+func waitForFile(path string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return microerror.Maskf(executionFailedError, "%q did not appear within %s", path, timeout)
+		}
+		time.Sleep(statInterval)
+	}
+}
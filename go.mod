@@ -7,6 +7,7 @@ require (
 	github.com/giantswarm/backoff v1.0.0
 	github.com/giantswarm/microerror v0.4.1
 	github.com/spf13/pflag v1.0.5
+	github.com/vishvananda/netlink v1.3.1
 )
 
 require (
@@ -17,4 +18,6 @@ require (
 	github.com/go-logfmt/logfmt v0.5.1 // indirect
 	github.com/go-stack/stack v1.8.1 // indirect
 	github.com/jmespath/go-jmespath v0.4.0 // indirect
+	github.com/vishvananda/netns v0.0.5 // indirect
+	golang.org/x/sys v0.13.0 // indirect
 )
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"strconv"
 
+	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/giantswarm/microerror"
 	flag "github.com/spf13/pflag"
 
@@ -11,19 +15,66 @@ import (
 	"github.com/giantswarm/aws-attach-etcd-dep/disk"
 	"github.com/giantswarm/aws-attach-etcd-dep/metadata"
 	"github.com/giantswarm/aws-attach-etcd-dep/pkg/project"
+	"github.com/giantswarm/aws-attach-etcd-dep/routing"
 )
 
 type Flag struct {
-	EniDeviceIndex     int64
-	EniForceDetach     bool
-	EniTagKey          string
-	EniTagValue        string
-	VolumeDeviceName   string
-	VolumeDeviceFsType string
-	VolumeDeviceLabel  string
-	VolumeForceDetach  bool
-	VolumeTagKey       string
-	VolumeTagValue     string
+	DNSHostedZoneID           string
+	DNSRecordName             string
+	DNSRecordTTL              int64
+	EniDeviceIndex            int64
+	EniFilters                []string
+	EniForceDetach            bool
+	EniIPv6Gateway            string
+	EniNetworkCardIndex       int64
+	EniPool                   bool
+	EniSecurityGroupIDs       []string
+	EniSecurityGroupTags      []string
+	EniSourceDestCheck        string
+	EniTagKey                 string
+	EniTagValue               string
+	EniTieBreakers            []string
+	EtcdClusterID             string
+	EtcdClusterIDTagKey       string
+	EtcdConfigOutput          string
+	EtcdConfigTemplate        string
+	EtcdDataDir               string
+	EtcdMemberID              string
+	EtcdMemberIDTagKey        string
+	EtcdMemberNameTagKey      string
+	EtcdVerifyDataDir         bool
+	MountNow                  bool
+	MountOptions              string
+	MountPath                 string
+	MountUnitDir              string
+	MountWhat                 string
+	PoolClaimTagKey           string
+	ResultDir                 string
+	RoutingBackend            string
+	RoutingExtraRoutes        []string
+	RoutingMetric             int
+	RoutingRulePriority       int
+	RoutingTable              int
+	RoutingVerify             bool
+	RoutingVerifyPeer         string
+	VolumeAllowFormatNonEmpty bool
+	VolumeDeviceName          string
+	VolumeEncryptionKeyFile   string
+	VolumeEncryptionKeySource string
+	VolumeEncryptionKeySSM    string
+	VolumeEncryptionKeyTag    string
+	VolumeEncryptionMapper    string
+	VolumeDeviceFsType        string
+	VolumeDeviceLabel         string
+	VolumeFilters             []string
+	VolumeForceDetach         bool
+	VolumeFsck                string
+	VolumeFsckTagKey          string
+	VolumeMultiAttachPolicy   string
+	VolumePool                bool
+	VolumeTagKey              string
+	VolumeTagValue            string
+	VolumeTieBreakers         []string
 }
 
 func main() {
@@ -37,17 +88,66 @@ func mainError() error {
 	var err error
 
 	var f Flag
-	flag.Int64Var(&f.EniDeviceIndex, "eni-device-index", 1, "NIC Device index that will be used for attaching the ENI. Cannot be zeroas that is the default NCI that is already attached.")
+	flag.Int64Var(&f.EniDeviceIndex, "eni-device-index", 0, "NIC Device index that will be used for attaching the ENI. If zero, the lowest free device index of the instance is used as zero is the default NIC that is already attached.")
+	flag.Int64Var(&f.EniNetworkCardIndex, "eni-network-card-index", -1, "Network card index that will be used for attaching the ENI. If -1, the lowest network card with free capacity is used when selecting the device index, otherwise the default network card.")
 	flag.BoolVar(&f.EniForceDetach, "eni-force-detach", false, "If set to true, app will use force-detach if the ENI cannot be detached by normal detach operation..")
+	flag.StringVar(&f.EniIPv6Gateway, "eni-ipv6-gateway", routing.DefaultIPv6Gateway, "Link-local address of the VPC router used as IPv6 default gateway for the ENI.")
+	flag.BoolVar(&f.EniPool, "eni-pool", false, "If set to true, the ENI filters may match several ENIs and an available one in the availability zone of the instance is claimed.")
+	flag.StringSliceVar(&f.EniSecurityGroupIDs, "eni-security-group-ids", nil, "Desired security group IDs of the ENI. If set together with or instead of --eni-security-group-tags, drift is reconciled.")
+	flag.StringSliceVar(&f.EniSecurityGroupTags, "eni-security-group-tags", nil, "Tags in the form key=value selecting the desired security groups of the ENI in its VPC.")
+	flag.StringVar(&f.EniSourceDestCheck, "eni-source-dest-check", "", "Desired source/destination check of the ENI, either 'true' or 'false'. If empty, it is not managed.")
+	flag.StringArrayVar(&f.EniFilters, "eni-filter", nil, "Additional EC2 filter in the form name=value the ENI must match, e.g. tag:etcd-member=1 or availability-zone=eu-west-1a. Can be repeated, values may contain * and ? wildcards.")
 	flag.StringVar(&f.EniTagKey, "eni-tag-key", "aws-attach-by-id", "Tag key that will be used to found the requested ENI in AWS API.")
-	flag.StringVar(&f.EniTagValue, "eni-tag-value", "test", "Tag value that will be used to found the requested ENI in AWS API, this tag should identify one unique ENI.")
+	flag.StringSliceVar(&f.EniTieBreakers, "eni-tie-breakers", nil, "Tie-breakers applied in order when the filters match several ENIs, any of 'same-az' or 'attached-to-me'. If empty, an ambiguous match fails.")
+	flag.StringVar(&f.EniTagValue, "eni-tag-value", "test", "Tag value that will be used to found the requested ENI in AWS API, this tag should identify one unique ENI. Can be a template using the instance tags, e.g. '{{ .InstanceTags.etcd_member }}'.")
 
+	flag.BoolVar(&f.VolumeAllowFormatNonEmpty, "allow-format-nonempty", false, "If set to true, app will format the EBS device even if it carries partition tables, unknown signatures or non-zero data.")
 	flag.StringVar(&f.VolumeDeviceName, "volume-device-name", "/dev/xvdh", "Volume device name that will be used for attaching the EBS volume.")
+	flag.StringVar(&f.VolumeEncryptionKeySource, "volume-encryption-key-source", "", "If set, the EBS device is encrypted with LUKS2 using a key from this source, one of 'file', 'kms-tag' or 'ssm'.")
+	flag.StringVar(&f.VolumeEncryptionKeyFile, "volume-encryption-key-file", "", "File containing the LUKS key, used with key source 'file'. A trailing newline is not part of the key.")
+	flag.StringVar(&f.VolumeEncryptionKeySSM, "volume-encryption-key-ssm-parameter", "", "Name of the SSM parameter containing the LUKS key, used with key source 'ssm'.")
+	flag.StringVar(&f.VolumeEncryptionKeyTag, "volume-encryption-key-tag-key", "aws-attach-etcd-dep/luks-key", "Volume tag containing the base64 encoded KMS ciphertext of the LUKS key, used with key source 'kms-tag'.")
+	flag.StringVar(&f.VolumeEncryptionMapper, "volume-encryption-mapper-name", "etcd", "Device mapper name used when opening the LUKS container.")
 	flag.StringVar(&f.VolumeDeviceFsType, "volume-device-filesystem-type", "ext4", "In case that the EBS device has no file-system, it will be formatted using this value.")
 	flag.StringVar(&f.VolumeDeviceLabel, "volume-device-label", "var-lib-etcd", "In case that the EBS device has no file-system, it will be formatted  with this label.")
 	flag.BoolVar(&f.VolumeForceDetach, "volume-force-detach", false, "If set to true, app will use force-detach if the EBS cannot be detached by normal detach operation.")
+	flag.StringVar(&f.VolumeFsck, "volume-fsck", "auto", "When to check an existing file-system on the EBS device, one of 'auto', 'always' or 'never'. With 'auto' the check runs when the volume was force-detached or is tagged as uncleanly released.")
+	flag.StringVar(&f.VolumeFsckTagKey, "volume-fsck-tag-key", "aws-attach-etcd-dep/unclean-release", "Volume tag which, if set to 'true', marks the volume as uncleanly released.")
+	flag.StringArrayVar(&f.VolumeFilters, "volume-filter", nil, "Additional EC2 filter in the form name=value the EBS must match, e.g. tag:etcd-member=1 or volume-type=gp3. Can be repeated, values may contain * and ? wildcards.")
+	flag.StringVar(&f.VolumeMultiAttachPolicy, "volume-multi-attach-policy", aws.MultiAttachPolicyShare, "How a volume with Multi-Attach enabled which is attached to other instances is handled, one of 'share' to attach it alongside, 'exclusive' to detach the others first or 'refuse' to fail.")
+	flag.BoolVar(&f.VolumePool, "volume-pool", false, "If set to true, the EBS filters may match several volumes and an available one in the availability zone of the instance is claimed.")
 	flag.StringVar(&f.VolumeTagKey, "volume-tag-key", "aws-attach-by-id", "Tag key that will be used to found the requested EBS in AWS API.")
-	flag.StringVar(&f.VolumeTagValue, "volume-tag-value", "test", "Tag value that will be used to found the requested EBS in AWS API, this tag should identify one unique EBS.")
+	flag.StringSliceVar(&f.VolumeTieBreakers, "volume-tie-breakers", nil, "Tie-breakers applied in order when the filters match several volumes, any of 'same-az', 'attached-to-me', 'newest' or 'oldest'. If empty, an ambiguous match fails.")
+	flag.StringVar(&f.VolumeTagValue, "volume-tag-value", "test", "Tag value that will be used to found the requested EBS in AWS API, this tag should identify one unique EBS. Can be a template using the instance tags, e.g. '{{ .InstanceTags.etcd_member }}'.")
+
+	flag.StringVar(&f.ResultDir, "result-dir", "/run/aws-attach-etcd-dep", "Directory where result.env and result.json describing the attached ENI and volume are written. If empty, no result is written.")
+	flag.StringVar(&f.PoolClaimTagKey, "pool-claim-tag-key", aws.DefaultPoolClaimTagKey, "Tag set to the instance ID when claiming an ENI or EBS in pool mode.")
+
+	flag.StringVar(&f.EtcdConfigTemplate, "etcd-config-template", "", "Go template file rendered into --etcd-config-output with the ENI address, member name and data dir. If empty, no etcd configuration is rendered.")
+	flag.StringVar(&f.EtcdConfigOutput, "etcd-config-output", "/etc/etcd/etcd.env", "File where the rendered etcd configuration is written, e.g. an environment file or a YAML config.")
+	flag.StringVar(&f.EtcdDataDir, "etcd-data-dir", "", "etcd data dir available in the etcd configuration template. If empty, --mount-path is used.")
+	flag.StringVar(&f.EtcdMemberNameTagKey, "etcd-member-name-tag-key", "aws-attach-etcd-dep/etcd-member-name", "Volume tag holding the etcd member name available in the etcd configuration template.")
+	flag.BoolVar(&f.EtcdVerifyDataDir, "etcd-verify-data-dir", false, "If set to true, the member and cluster ID in the WAL metadata of the etcd data dir must match the expected IDs, requires the data dir to be mounted.")
+	flag.StringVar(&f.EtcdMemberID, "etcd-member-id", "", "Expected etcd member ID in hex. If empty, the --etcd-member-id-tag-key volume tag is used.")
+	flag.StringVar(&f.EtcdMemberIDTagKey, "etcd-member-id-tag-key", "aws-attach-etcd-dep/etcd-member-id", "Volume tag holding the expected etcd member ID in hex.")
+	flag.StringVar(&f.EtcdClusterID, "etcd-cluster-id", "", "Expected etcd cluster ID in hex. If empty, the --etcd-cluster-id-tag-key volume tag is used.")
+	flag.StringVar(&f.EtcdClusterIDTagKey, "etcd-cluster-id-tag-key", "aws-attach-etcd-dep/etcd-cluster-id", "Volume tag holding the expected etcd cluster ID in hex.")
+
+	flag.StringVar(&f.DNSHostedZoneID, "dns-hosted-zone-id", "", "Route53 hosted zone in which the DNS record for the ENI is upserted. If empty, no DNS record is managed.")
+	flag.StringVar(&f.DNSRecordName, "dns-record-name", "", "Name of the A/AAAA record pointing to the ENI addresses, e.g. etcd1.cluster.internal.")
+	flag.Int64Var(&f.DNSRecordTTL, "dns-record-ttl", 60, "TTL of the DNS record pointing to the ENI addresses.")
+	flag.StringVar(&f.RoutingBackend, "routing-backend", routing.BackendNetworkd, "How routing for the ENI is configured, one of 'networkd', 'netplan', 'networkmanager', 'ifupdown', 'netlink' to configure the kernel directly or 'auto' to detect the tool managing the host.")
+	flag.StringSliceVar(&f.RoutingExtraRoutes, "routing-extra-routes", nil, "Additional destinations in CIDR notation that are routed via the ENI gateway in the ENI routing table.")
+	flag.IntVar(&f.RoutingMetric, "routing-metric", 0, "Metric of the routes in the ENI routing table, 0 leaves it unset.")
+	flag.IntVar(&f.RoutingRulePriority, "routing-rule-priority", 0, "Priority of the routing policy rules for the ENI addresses, 0 leaves it to the kernel. Required with the 'networkmanager' backend.")
+	flag.IntVar(&f.RoutingTable, "routing-table", routing.DefaultTable, "Routing table used for traffic originating from the ENI addresses.")
+	flag.BoolVar(&f.RoutingVerify, "routing-verify", false, "Verify the ENI interface is up and traffic from the ENI address is routed via the ENI gateway once routing is configured.")
+	flag.StringVar(&f.RoutingVerifyPeer, "routing-verify-peer", "", "Optional host:port which must be reachable via TCP from the ENI address, implies --routing-verify.")
+	flag.BoolVar(&f.MountNow, "mount-now", false, "If set to true, app will start the systemd mount unit right away instead of leaving it to the next boot. Without reachable systemd the device is mounted directly.")
+	flag.StringVar(&f.MountOptions, "mount-options", "defaults", "Mount options that will be used for the EBS device.")
+	flag.StringVar(&f.MountPath, "mount-path", "", "Path where the EBS device will be mounted. If empty, no mount unit is written and the device is not mounted.")
+	flag.StringVar(&f.MountUnitDir, "mount-unit-dir", "/etc/systemd/system", "Directory where the systemd mount unit for the EBS device will be written, the unit is enabled with systemctl if systemd is reachable.")
+	flag.StringVar(&f.MountWhat, "mount-what", disk.MountWhatLabel, "How the systemd mount unit references the EBS device, either by 'label' or by 'uuid'.")
 
 	if len(os.Args) > 1 && os.Args[1] == "version" {
 		fmt.Printf("%s:%s - %s", project.Name(), project.Version(), project.GitSHA())
@@ -59,6 +159,11 @@ func mainError() error {
 	}
 	flag.Parse()
 
+	err = validateFlags(f)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
 	awsSession, err := getAWSSession()
 	if err != nil {
 		return microerror.Mask(err)
@@ -68,16 +173,43 @@ func mainError() error {
 	if err != nil {
 		return microerror.Mask(err)
 	}
-	// attach ENI here
+
+	err = renderTagTemplates(&f, awsSession, instanceID)
+	if err != nil {
+		return microerror.Mask(err)
+	}
 	var eni *aws.ENI
 	{
+		sourceDestCheck, err := parseOptionalBool(f.EniSourceDestCheck)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
 		eniConfig := aws.ENIConfig{
-			AWSInstanceID: instanceID,
-			AwsSession:    awsSession,
-			DeviceIndex:   f.EniDeviceIndex,
-			ForceDetach:   f.EniForceDetach,
-			TagKey:        f.EniTagKey,
-			TagValue:      f.EniTagValue,
+			AWSInstanceID:    instanceID,
+			AwsSession:       awsSession,
+			DeviceIndex:      f.EniDeviceIndex,
+			Filters:          f.EniFilters,
+			ForceDetach:      f.EniForceDetach,
+			NetworkCardIndex: f.EniNetworkCardIndex,
+			Pool:             f.EniPool,
+			PoolClaimTagKey:  f.PoolClaimTagKey,
+			Routing: routing.Config{
+				Backend:      f.RoutingBackend,
+				ExtraRoutes:  f.RoutingExtraRoutes,
+				IPv6Gateway:  f.EniIPv6Gateway,
+				Metric:       f.RoutingMetric,
+				RulePriority: f.RoutingRulePriority,
+				Table:        f.RoutingTable,
+				Verify:       f.RoutingVerify || f.RoutingVerifyPeer != "",
+				VerifyPeer:   f.RoutingVerifyPeer,
+			},
+			SecurityGroupIDs:  f.EniSecurityGroupIDs,
+			SecurityGroupTags: f.EniSecurityGroupTags,
+			SourceDestCheck:   sourceDestCheck,
+			TagKey:            f.EniTagKey,
+			TagValue:          f.EniTagValue,
+			TieBreakers:       f.EniTieBreakers,
 		}
 
 		eni, err = aws.NewENI(eniConfig)
@@ -86,21 +218,20 @@ func mainError() error {
 		}
 	}
 
-	err = eni.AttachByTag()
-
-	if err != nil {
-		return microerror.Mask(err)
-	}
-	// attach EBS here
 	var ebs *aws.EBS
 	{
 		ebsConfig := aws.EBSConfig{
-			AWSInstanceID: instanceID,
-			AwsSession:    awsSession,
-			DeviceName:    f.VolumeDeviceName,
-			ForceDetach:   f.VolumeForceDetach,
-			TagKey:        f.VolumeTagKey,
-			TagValue:      f.VolumeTagValue,
+			AWSInstanceID:     instanceID,
+			AwsSession:        awsSession,
+			DeviceName:        f.VolumeDeviceName,
+			Filters:           f.VolumeFilters,
+			ForceDetach:       f.VolumeForceDetach,
+			MultiAttachPolicy: f.VolumeMultiAttachPolicy,
+			Pool:              f.VolumePool,
+			PoolClaimTagKey:   f.PoolClaimTagKey,
+			TagKey:            f.VolumeTagKey,
+			TagValue:          f.VolumeTagValue,
+			TieBreakers:       f.VolumeTieBreakers,
 		}
 
 		ebs, err = aws.NewEBS(ebsConfig)
@@ -109,6 +240,43 @@ func mainError() error {
 		}
 	}
 
+	if flag.Arg(0) == "status" {
+		err = printStatus(eni, ebs)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		return nil
+	}
+
+	// attach ENI here
+	err = eni.AttachByTag()
+
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if f.DNSHostedZoneID != "" {
+		var dns *aws.DNS
+		{
+			dnsConfig := aws.DNSConfig{
+				AwsSession:   awsSession,
+				HostedZoneID: f.DNSHostedZoneID,
+				RecordName:   f.DNSRecordName,
+				TTL:          f.DNSRecordTTL,
+			}
+
+			dns, err = aws.NewDNS(dnsConfig)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+		}
+
+		err = dns.UpsertRecords(eni.Addresses())
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+	// attach EBS here
 	err = ebs.AttachByTag()
 	if err != nil {
 		return microerror.Mask(err)
@@ -119,9 +287,278 @@ func mainError() error {
 	if err != nil {
 		return microerror.Mask(err)
 	}
-	err = disk.EnsureDiskHasFileSystem(f.VolumeDeviceName, f.VolumeDeviceFsType, f.VolumeDeviceLabel)
+
+	fsDeviceName := f.VolumeDeviceName
+	if f.VolumeEncryptionKeySource != "" {
+		key, err := getEncryptionKey(f, awsSession, ebs)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		var luks *disk.LUKS
+		{
+			luksConfig := disk.LUKSConfig{
+				AllowFormatNonEmpty: f.VolumeAllowFormatNonEmpty,
+				DeviceName:          f.VolumeDeviceName,
+				Key:                 key,
+				MapperName:          f.VolumeEncryptionMapper,
+			}
+
+			luks, err = disk.NewLUKS(luksConfig)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+		}
+
+		err = luks.EnsureOpened()
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		err = checkFileSystem(f, ebs, luks.MapperDevice())
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		err = luks.EnsureHasFileSystem(f.VolumeDeviceFsType, f.VolumeDeviceLabel)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		fsDeviceName = luks.MapperDevice()
+	} else {
+		err = checkFileSystem(f, ebs, f.VolumeDeviceName)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		err = disk.EnsureDiskHasFileSystem(f.VolumeDeviceName, f.VolumeDeviceFsType, f.VolumeDeviceLabel, f.VolumeAllowFormatNonEmpty)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	if f.MountPath != "" {
+		var mount *disk.Mount
+		{
+			mountConfig := disk.MountConfig{
+				DeviceName: fsDeviceName,
+				FsType:     f.VolumeDeviceFsType,
+				MountNow:   f.MountNow,
+				Options:    f.MountOptions,
+				Path:       f.MountPath,
+				UnitDir:    f.MountUnitDir,
+				What:       f.MountWhat,
+			}
+
+			mount, err = disk.NewMount(mountConfig)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+		}
+
+		err = mount.EnsureMounted()
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	if f.EtcdVerifyDataDir {
+		err = verifyEtcdDataDir(f, ebs, fsDeviceName)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	if f.EtcdConfigTemplate != "" {
+		err = renderEtcdConfig(f, eni, ebs)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	if f.ResultDir != "" {
+		interfaceName, err := eni.InterfaceName()
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		r := result{
+			DeviceName:       fsDeviceName,
+			ENIAddresses:     eni.AllAddresses(),
+			ENIID:            eni.ID(),
+			ENIIP:            eni.Addresses()[0],
+			InterfaceName:    interfaceName,
+			MountPath:        f.MountPath,
+			VolumeDeviceName: f.VolumeDeviceName,
+			VolumeID:         ebs.ID(),
+		}
+
+		err = writeResult(f.ResultDir, r)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+	return nil
+}
+
+// verifyEtcdDataDir refuses to continue if the etcd data dir on the volume
+// belongs to another member or cluster.
+func verifyEtcdDataDir(f Flag, ebs *aws.EBS, fsDeviceName string) error {
+	if etcdDataDir(f) == "" {
+		return microerror.Maskf(invalidFlagError, "--etcd-data-dir or --mount-path must be set to verify the etcd data dir")
+	}
+	if f.MountPath != "" && !f.MountNow {
+		return microerror.Maskf(invalidFlagError, "--mount-now must be set to verify the etcd data dir on the volume")
+	}
+
+	var expected disk.EtcdMembership
+	{
+		memberID, err := expectedEtcdID(ebs, f.EtcdMemberID, f.EtcdMemberIDTagKey)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		clusterID, err := expectedEtcdID(ebs, f.EtcdClusterID, f.EtcdClusterIDTagKey)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		if memberID == 0 && clusterID == 0 {
+			return microerror.Maskf(invalidFlagError, "expected etcd member or cluster ID must be set by flag or volume tag to verify the etcd data dir")
+		}
+
+		expected = disk.EtcdMembership{
+			ClusterID: clusterID,
+			MemberID:  memberID,
+		}
+	}
+
+	err := disk.VerifyEtcdDataDir(etcdDataDir(f), fsDeviceName, expected)
 	if err != nil {
 		return microerror.Mask(err)
 	}
 	return nil
 }
+
+// expectedEtcdID parses the hex ID given by flag or, if empty, by volume tag.
+// Zero means the ID is not known.
+func expectedEtcdID(ebs *aws.EBS, value string, tagKey string) (uint64, error) {
+	if value == "" {
+		value, _ = ebs.Tag(tagKey)
+	}
+	if value == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseUint(value, 16, 64)
+	if err != nil {
+		return 0, microerror.Maskf(invalidFlagError, "expected etcd ID %q must be in hex", value)
+	}
+	return id, nil
+}
+
+func etcdDataDir(f Flag) string {
+	if f.EtcdDataDir != "" {
+		return f.EtcdDataDir
+	}
+	return f.MountPath
+}
+
+func renderEtcdConfig(f Flag, eni *aws.ENI, ebs *aws.EBS) error {
+	interfaceName, err := eni.InterfaceName()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	memberName, ok := ebs.Tag(f.EtcdMemberNameTagKey)
+	if !ok {
+		return microerror.Maskf(invalidFlagError, "volume %q has no tag %q holding the etcd member name", ebs.ID(), f.EtcdMemberNameTagKey)
+	}
+
+	addresses := eni.Addresses()
+	etcdConfig := routing.EtcdConfig{
+		OutputFile: f.EtcdConfigOutput,
+		Params: routing.EtcdParams{
+			DataDir:       etcdDataDir(f),
+			ENIAddress:    addresses[0],
+			ENIAddresses:  addresses,
+			ENIID:         eni.ID(),
+			InterfaceName: interfaceName,
+			MemberName:    memberName,
+			VolumeID:      ebs.ID(),
+		},
+		TemplateFile: f.EtcdConfigTemplate,
+	}
+
+	err = routing.RenderEtcdConfig(etcdConfig)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	return nil
+}
+
+func getEncryptionKey(f Flag, awsSession *session.Session, ebs *aws.EBS) ([]byte, error) {
+	switch f.VolumeEncryptionKeySource {
+	case "file":
+		key, err := ioutil.ReadFile(f.VolumeEncryptionKeyFile)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		// editors and echo add a trailing newline which is not part of the key
+		return bytes.TrimRight(key, "\r\n"), nil
+	case "kms-tag":
+		key, err := ebs.DecryptKMSTag(f.VolumeEncryptionKeyTag)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		return key, nil
+	case "ssm":
+		key, err := aws.GetSSMParameter(awsSession, f.VolumeEncryptionKeySSM)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		return key, nil
+	}
+
+	return nil, microerror.Maskf(invalidFlagError, "unknown volume encryption key source %q", f.VolumeEncryptionKeySource)
+}
+
+// validateFlags checks flag values which are otherwise only used after the
+// ENI and the EBS volume have been attached.
+func validateFlags(f Flag) error {
+	switch f.VolumeFsck {
+	case "auto", "always", "never":
+	default:
+		return microerror.Maskf(invalidFlagError, "unknown volume fsck mode %q", f.VolumeFsck)
+	}
+	return nil
+}
+
+func checkFileSystem(f Flag, ebs *aws.EBS, deviceName string) error {
+	switch f.VolumeFsck {
+	case "never":
+		return nil
+	case "always":
+	case "auto":
+		unclean, _ := ebs.Tag(f.VolumeFsckTagKey)
+		if !ebs.ForceDetached() && unclean != "true" {
+			return nil
+		}
+		fmt.Printf("Volume was force-detached or released uncleanly, checking file-system.\n")
+	default:
+		return microerror.Maskf(invalidFlagError, "unknown volume fsck mode %q", f.VolumeFsck)
+	}
+
+	err := disk.CheckFileSystem(deviceName)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	return nil
+}
+
+// parseOptionalBool parses "true" or "false", an empty value means unset.
+func parseOptionalBool(value string) (*bool, error) {
+	if value == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, microerror.Maskf(invalidFlagError, "expected 'true' or 'false' but got %q", value)
+	}
+	return &b, nil
+}
@@ -1,12 +1,18 @@
 package metadata
 
 import (
+	"path"
+	"strings"
+
 	"github.com/aws/aws-sdk-go/aws/ec2metadata"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/giantswarm/microerror"
 )
 
-const metadataEndpointInstanceID = "instance-id"
+const (
+	metadataEndpointInstanceID   = "instance-id"
+	metadataEndpointInstanceTags = "tags/instance"
+)
 
 func GetInstanceID(session *session.Session) (string, error) {
 	ec2metadataClient := ec2metadata.New(session)
@@ -29,3 +35,28 @@ func GetRegion(session *session.Session) (string, error) {
 
 	return doc.Region, nil
 }
+
+// GetInstanceTags returns the tags of the instance. Access to tags in instance
+// metadata must be enabled in the instance metadata options.
+func GetInstanceTags(session *session.Session) (map[string]string, error) {
+	ec2metadataClient := ec2metadata.New(session)
+
+	keys, err := ec2metadataClient.GetMetadata(metadataEndpointInstanceTags)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	tags := map[string]string{}
+	for _, key := range strings.Split(strings.TrimSpace(keys), "\n") {
+		if key == "" {
+			continue
+		}
+		value, err := ec2metadataClient.GetMetadata(path.Join(metadataEndpointInstanceTags, key))
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		tags[key] = value
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	resultEnvFile  = "result.env"
+	resultJSONFile = "result.json"
+)
+
+// result holds everything resolved by a successful run, downstream units like
+// etcd consume it via EnvironmentFile= instead of rediscovering it.
+type result struct {
+	// DeviceName is the device carrying the file-system, i.e. the LUKS
+	// mapper device if the volume is encrypted.
+	DeviceName string `json:"deviceName"`
+	// ENIAddresses are all addresses of the ENI including secondary
+	// addresses and delegated prefixes in CIDR notation.
+	ENIAddresses     []string `json:"eniAddresses"`
+	ENIID            string   `json:"eniID"`
+	ENIIP            string   `json:"eniIP"`
+	InterfaceName    string   `json:"interfaceName"`
+	MountPath        string   `json:"mountPath"`
+	VolumeDeviceName string   `json:"volumeDeviceName"`
+	VolumeID         string   `json:"volumeID"`
+}
+
+func (r result) env() []byte {
+	vars := []struct {
+		name  string
+		value string
+	}{
+		{"DEVICE_NAME", r.DeviceName},
+		{"ENI_ADDRESSES", strings.Join(r.ENIAddresses, " ")},
+		{"ENI_ID", r.ENIID},
+		{"ENI_IP", r.ENIIP},
+		{"INTERFACE_NAME", r.InterfaceName},
+		{"MOUNT_PATH", r.MountPath},
+		{"VOLUME_DEVICE_NAME", r.VolumeDeviceName},
+		{"VOLUME_ID", r.VolumeID},
+	}
+
+	var buff bytes.Buffer
+	for _, v := range vars {
+		fmt.Fprintf(&buff, "AWS_ATTACH_ETCD_DEP_%s=%s\n", v.name, systemdQuote(v.value))
+	}
+	return buff.Bytes()
+}
+
+// systemdQuote double quotes a value for EnvironmentFile=, which only knows
+// the backslash escapes \", \\, \$ and \` within double quotes. Newlines
+// cannot be represented and are replaced by spaces.
+func systemdQuote(value string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "`", "\\`", "\n", " ", "\r", " ")
+	return `"` + r.Replace(value) + `"`
+}
+
+// writeResult writes the result as env file and as JSON to dir. Both files
+// are replaced atomically, so readers never see partial content.
+func writeResult(dir string, r result) error {
+	err := os.MkdirAll(dir, 0755) // nolint
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	j, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = writeFileAtomic(filepath.Join(dir, resultEnvFile), r.env())
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	err = writeFileAtomic(filepath.Join(dir, resultJSONFile), append(j, '\n'))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	fmt.Printf("Wrote result to %q.\n", dir)
+	return nil
+}
+
+func writeFileAtomic(fileName string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(fileName), "."+filepath.Base(fileName)+".")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	defer os.Remove(f.Name()) // nolint
+
+	_, err = f.Write(data)
+	if err != nil {
+		f.Close()
+		return microerror.Mask(err)
+	}
+	err = f.Chmod(0644)
+	if err != nil {
+		f.Close()
+		return microerror.Mask(err)
+	}
+	err = f.Sync()
+	if err != nil {
+		f.Close()
+		return microerror.Mask(err)
+	}
+	err = f.Close()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = os.Rename(f.Name(), fileName)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package routing
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	BackendAuto           = "auto"
+	BackendIfupdown       = "ifupdown"
+	BackendNetlink        = "netlink"
+	BackendNetplan        = "netplan"
+	BackendNetworkManager = "networkmanager"
+	BackendNetworkd       = "networkd"
+
+	ifupdownFileName       = "/etc/network/interfaces.d/%s"
+	netplanFileName        = "/etc/netplan/60-%s.yaml"
+	networkManagerFileName = "/etc/NetworkManager/system-connections/%s.nmconnection"
+	networkdFileName       = "/etc/systemd/network/10-%s.network"
+)
+
+var templateFuncs = template.FuncMap{
+	// keyfile entries are numbered starting at one
+	"inc": func(i int) int { return i + 1 },
+	"add": func(i int, j int) int { return i + j },
+}
+
+// backend configures the source based policy routing for the ENI.
+type backend interface {
+	apply(p params) error
+}
+
+// fileBackend renders the routing configuration into a file consumed by the
+// network configuration tool of the host.
+type fileBackend struct {
+	fileMode os.FileMode
+	fileName string
+	template string
+}
+
+func (b fileBackend) apply(p params) error {
+	content, err := b.render(p)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(b.fileName), 0755) // nolint
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = ioutil.WriteFile(b.fileName, content, b.fileMode) // nolint
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	fmt.Printf("Wrote routing configuration to %q.\n", b.fileName)
+	return nil
+}
+
+func (b fileBackend) render(p params) ([]byte, error) {
+	var buff bytes.Buffer
+	t := template.Must(template.New("routing").Funcs(templateFuncs).Parse(b.template))
+
+	err := t.Execute(&buff, p)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return buff.Bytes(), nil
+}
+
+type netlinkBackend struct{}
+
+func (b netlinkBackend) apply(p params) error {
+	err := applyNetlink(p)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	return nil
+}
+
+func newBackend(name string, p params) (backend, error) {
+	if name == BackendAuto {
+		name = detectBackend()
+		fmt.Printf("Detected routing backend %q.\n", name)
+	}
+
+	interfaceName := p.InterfaceName
+
+	switch name {
+	case BackendIfupdown:
+		return fileBackend{fileMode: 0644, fileName: fmt.Sprintf(ifupdownFileName, interfaceName), template: ifupdownRoutingTemplate}, nil
+	case BackendNetlink:
+		return netlinkBackend{}, nil
+	case BackendNetplan:
+		// netplan warns about world readable configuration files
+		return fileBackend{fileMode: 0600, fileName: fmt.Sprintf(netplanFileName, interfaceName), template: netplanRoutingTemplate}, nil
+	case BackendNetworkManager:
+		// NetworkManager rejects routing rules without a priority
+		if p.RulePriority == 0 {
+			return nil, microerror.Maskf(invalidConfigError, "routing backend %q requires a rule priority", BackendNetworkManager)
+		}
+		// NetworkManager ignores keyfiles readable by others
+		return fileBackend{fileMode: 0600, fileName: fmt.Sprintf(networkManagerFileName, interfaceName), template: networkManagerRoutingTemplate}, nil
+	case BackendNetworkd:
+		return fileBackend{fileMode: 0644, fileName: fmt.Sprintf(networkdFileName, interfaceName), template: networkRoutingTemplate}, nil
+	}
+
+	return nil, microerror.Maskf(invalidConfigError, "unknown routing backend %q", name)
+}
+
+// detectBackend guesses the network configuration tool managing the host.
+// netplan is checked first as it renders its configuration for networkd or
+// NetworkManager, which would otherwise be detected.
+func detectBackend() string {
+	if matches, _ := filepath.Glob("/etc/netplan/*.yaml"); len(matches) > 0 {
+		return BackendNetplan
+	}
+	if exists("/run/NetworkManager") {
+		return BackendNetworkManager
+	}
+	if exists("/run/systemd/netif") {
+		return BackendNetworkd
+	}
+	if exists("/etc/network/interfaces") {
+		return BackendIfupdown
+	}
+
+	return BackendNetworkd
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
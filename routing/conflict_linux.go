@@ -0,0 +1,59 @@
+//go:build linux
+
+package routing
+
+import (
+	"github.com/giantswarm/microerror"
+	"github.com/vishvananda/netlink"
+)
+
+// listRules and listRoutes are replaced in tests.
+var (
+	listRules  = netlink.RuleList
+	listRoutes = netlink.RouteListFiltered
+)
+
+// detectConflicts checks that neither the routing table nor the rule
+// priority are used by somebody else on the host, e.g. a CNI plugin. Rules
+// from the ENI addresses are ours, e.g. set by the network configuration tool
+// before a reboot, and routes of the ENI interface are ours as well.
+func detectConflicts(p params) error {
+	link, err := netlink.LinkByName(p.InterfaceName)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	families, err := parseParams(p)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	for _, c := range families {
+		rules, err := listRules(c.family)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		for _, r := range rules {
+			ours := r.Src != nil && containsAddress(c.addresses, r.Src)
+			if r.Table == c.table && !ours {
+				return microerror.Maskf(routingConflictError, "routing table %d is already used by rule %s", c.table, r)
+			}
+			if c.rulePriority > 0 && r.Priority == c.rulePriority && !ours {
+				return microerror.Maskf(routingConflictError, "rule priority %d is already used by rule %s", c.rulePriority, r)
+			}
+		}
+
+		filter := &netlink.Route{Table: c.table}
+		routes, err := listRoutes(c.family, filter, netlink.RT_FILTER_TABLE)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		for _, r := range routes {
+			if r.LinkIndex != link.Attrs().Index {
+				return microerror.Maskf(routingConflictError, "routing table %d already contains route %s of another interface", c.table, r)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+//go:build linux
+
+package routing
+
+import (
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+func Test_ConfigureNetworkRoutingForENI_conflict(t *testing.T) {
+	link, err := netlink.LinkByName("lo")
+	if err != nil {
+		t.Skipf("loopback interface not available: %s", err)
+	}
+
+	ours := mustParseCIDR(t, "10.0.1.10/32")
+	other := mustParseCIDR(t, "10.0.2.10/32")
+
+	testCases := []struct {
+		name        string
+		backend     string
+		rules       []netlink.Rule
+		routes      []netlink.Route
+		expectedErr func(error) bool
+	}{
+		{
+			name:        "case 0: table used by a rule of another address",
+			backend:     BackendNetworkd,
+			rules:       []netlink.Rule{{Src: other, Table: DefaultTable, Priority: 500}},
+			expectedErr: IsRoutingConflict,
+		},
+		{
+			name:        "case 1: priority used by a rule of another table",
+			backend:     BackendNetplan,
+			rules:       []netlink.Rule{{Src: other, Table: 100, Priority: 1000}},
+			expectedErr: IsRoutingConflict,
+		},
+		{
+			name:        "case 2: table contains a route of another interface",
+			backend:     BackendIfupdown,
+			routes:      []netlink.Route{{LinkIndex: link.Attrs().Index + 1000, Table: DefaultTable}},
+			expectedErr: IsRoutingConflict,
+		},
+		{
+			name:    "case 3: rule of the ENI address and route of the ENI interface",
+			backend: BackendNetworkd,
+			rules:   []netlink.Rule{{Src: ours, Table: DefaultTable, Priority: 1000}},
+			routes:  []netlink.Route{{LinkIndex: link.Attrs().Index, Table: DefaultTable}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			listRules = func(family int) ([]netlink.Rule, error) {
+				if family != netlink.FAMILY_V4 {
+					return nil, nil
+				}
+				return tc.rules, nil
+			}
+			listRoutes = func(family int, filter *netlink.Route, filterMask uint64) ([]netlink.Route, error) {
+				if family != netlink.FAMILY_V4 {
+					return nil, nil
+				}
+				return tc.routes, nil
+			}
+			defer func() {
+				listRules = netlink.RuleList
+				listRoutes = netlink.RouteListFiltered
+			}()
+
+			config := Config{
+				Backend:       tc.backend,
+				ENIAddress:    "10.0.1.10",
+				ENISubnet:     mustParseCIDR(t, "10.0.1.0/24"),
+				InterfaceName: "lo",
+				RulePriority:  1000,
+				Table:         DefaultTable,
+			}
+
+			if tc.expectedErr == nil {
+				// the file backends write to the host, so only the
+				// detection is run for a configuration without conflict
+				p := params{
+					ENIAddresses:  []string{"10.0.1.10/32"},
+					ENIGateway:    "10.0.1.1",
+					ENISubnet:     "10.0.1.0/24",
+					InterfaceName: "lo",
+					RulePriority:  1000,
+					Table:         DefaultTable,
+				}
+				err := detectConflicts(p)
+				if err != nil {
+					t.Fatalf("unexpected error %#v", err)
+				}
+				return
+			}
+
+			err := ConfigureNetworkRoutingForENI(config)
+			if !tc.expectedErr(err) {
+				t.Fatalf("expected routing conflict error, got %#v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package routing
+
+import (
+	"fmt"
+)
+
+func detectConflicts(p params) error {
+	fmt.Printf("Detecting routing conflicts is only supported on linux, skipping.\n")
+	return nil
+}
@@ -1 +1,25 @@
 package routing
+
+import "github.com/giantswarm/microerror"
+
+var executionFailedError = &microerror.Error{
+	Kind: "executionFailedError",
+}
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var routingConflictError = &microerror.Error{
+	Kind: "routingConflictError",
+}
+
+// IsRoutingConflict asserts routingConflictError.
+func IsRoutingConflict(err error) bool {
+	return microerror.Cause(err) == routingConflictError
+}
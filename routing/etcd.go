@@ -0,0 +1,78 @@
+package routing
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/giantswarm/microerror"
+)
+
+// EtcdConfig renders the etcd configuration, e.g. an environment file or a
+// YAML config, from a user provided template. The template is executed with
+// EtcdParams, e.g.
+//
+//	ETCD_LISTEN_PEER_URLS=https://{{ .ENIAddress }}:2380
+//	ETCD_ADVERTISE_CLIENT_URLS=https://{{ .ENIAddress }}:2379
+//	ETCD_DATA_DIR={{ .DataDir }}
+//	ETCD_NAME={{ .MemberName }}
+type EtcdConfig struct {
+	Params       EtcdParams
+	OutputFile   string
+	TemplateFile string
+}
+
+// EtcdParams are available in the etcd configuration template.
+type EtcdParams struct {
+	DataDir       string
+	ENIAddress    string
+	ENIAddresses  []string
+	ENIID         string
+	InterfaceName string
+	MemberName    string
+	VolumeID      string
+}
+
+func RenderEtcdConfig(config EtcdConfig) error {
+	if config.OutputFile == "" {
+		return microerror.Maskf(invalidConfigError, "config.OutputFile must not be empty")
+	}
+	if config.TemplateFile == "" {
+		return microerror.Maskf(invalidConfigError, "config.TemplateFile must not be empty")
+	}
+	if config.Params.ENIAddress == "" {
+		return microerror.Maskf(invalidConfigError, "config.Params.ENIAddress must not be empty")
+	}
+
+	text, err := ioutil.ReadFile(config.TemplateFile)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	t, err := template.New(filepath.Base(config.TemplateFile)).Funcs(templateFuncs).Option("missingkey=error").Parse(string(text))
+	if err != nil {
+		return microerror.Maskf(invalidConfigError, "failed to parse etcd config template %q, err: %s", config.TemplateFile, err)
+	}
+
+	var buff bytes.Buffer
+	err = t.Execute(&buff, config.Params)
+	if err != nil {
+		return microerror.Maskf(executionFailedError, "failed to render etcd config template %q, err: %s", config.TemplateFile, err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(config.OutputFile), 0755) // nolint
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = ioutil.WriteFile(config.OutputFile, buff.Bytes(), 0644) // nolint
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	fmt.Printf("Wrote etcd configuration to %q.\n", config.OutputFile)
+	return nil
+}
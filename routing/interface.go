@@ -0,0 +1,52 @@
+package routing
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/backoff"
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	interfaceMaxRetries    = 120
+	interfaceRetryInterval = time.Second
+)
+
+// WaitForInterface waits until the kernel registered a link with the given
+// MAC address and returns its name. With predictable interface names the ENI
+// does not necessarily show up as eth1, e.g. ens6 or enp0s6.
+func WaitForInterface(macAddress string) (string, error) {
+	mac, err := net.ParseMAC(macAddress)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	var name string
+	b := backoff.NewMaxRetries(interfaceMaxRetries, interfaceRetryInterval)
+	o := func() error {
+		interfaces, err := net.Interfaces()
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		for _, i := range interfaces {
+			if strings.EqualFold(i.HardwareAddr.String(), mac.String()) {
+				name = i.Name
+				return nil
+			}
+		}
+
+		fmt.Printf("Waiting until interface with MAC address %q is registered by kernel.\n", macAddress)
+		return microerror.Maskf(executionFailedError, "no interface with MAC address %q", macAddress)
+	}
+	err = backoff.Retry(o, b)
+	if err != nil {
+		fmt.Printf("Failed to find interface with MAC address %q after %d retries.\n", macAddress, interfaceMaxRetries)
+		return "", microerror.Mask(err)
+	}
+
+	fmt.Printf("Found interface %q with MAC address %q.\n", name, macAddress)
+	return name, nil
+}
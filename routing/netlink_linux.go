@@ -0,0 +1,323 @@
+//go:build linux
+
+package routing
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/giantswarm/microerror"
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	// same scope as configured in the networkd file
+	addressScope = 2
+)
+
+// familyConfig holds everything applied to the interface for one address
+// family.
+type familyConfig struct {
+	addresses    []*net.IPNet
+	addressScope int
+	extraRoutes  []*net.IPNet
+	family       int
+	gateway      net.IP
+	metric       int
+	rulePriority int
+	subnet       *net.IPNet
+	table        int
+}
+
+// applyNetlink configures the same addresses, routing policy rules and routes
+// as the networkd file, but directly in the kernel. All operations are
+// idempotent, so running it again for an already configured ENI is a no-op.
+func applyNetlink(p params) error {
+	link, err := netlink.LinkByName(p.InterfaceName)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = netlink.LinkSetUp(link)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	families, err := parseParams(p)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	for _, c := range families {
+		for _, address := range c.addresses {
+			err = netlink.AddrReplace(link, &netlink.Addr{IPNet: address, Scope: c.addressScope})
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			rule, err := findRule(c, address)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+			if rule == nil {
+				r := netlink.NewRule()
+				r.Family = c.family
+				r.Src = address
+				r.Table = c.table
+				if c.rulePriority > 0 {
+					r.Priority = c.rulePriority
+				}
+
+				err = netlink.RuleAdd(r)
+				if err != nil {
+					return microerror.Mask(err)
+				}
+			}
+		}
+
+		for _, r := range desiredRoutes(link, c) {
+			route := r
+			err = netlink.RouteReplace(&route)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+		}
+
+		err = verifyNetlink(link, c)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		fmt.Printf("Configured addresses %s, policy rules and routes in table %d on %s via netlink.\n", c.addresses, c.table, p.InterfaceName)
+	}
+
+	return nil
+}
+
+// verifyNetlink reads back the kernel state and checks that everything
+// applied by applyNetlink is present.
+func verifyNetlink(link netlink.Link, c familyConfig) error {
+	addrs, err := netlink.AddrList(link, c.family)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	for _, address := range c.addresses {
+		found := false
+		for _, a := range addrs {
+			if a.IPNet.String() == address.String() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return microerror.Maskf(executionFailedError, "address %s is not configured on %s", address, link.Attrs().Name)
+		}
+
+		rule, err := findRule(c, address)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		if rule == nil {
+			return microerror.Maskf(executionFailedError, "routing policy rule from %s to table %d is missing", address, c.table)
+		}
+	}
+
+	filter := &netlink.Route{Table: c.table, LinkIndex: link.Attrs().Index}
+	routes, err := netlink.RouteListFiltered(c.family, filter, netlink.RT_FILTER_TABLE|netlink.RT_FILTER_OIF)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	for _, desired := range desiredRoutes(link, c) {
+		if !containsRoute(routes, desired) {
+			return microerror.Maskf(executionFailedError, "route to %s in table %d is missing", destination(desired), c.table)
+		}
+	}
+
+	return nil
+}
+
+func desiredRoutes(link netlink.Link, c familyConfig) []netlink.Route {
+	defaultDst := &net.IPNet{IP: net.IPv4zero.To4(), Mask: net.CIDRMask(0, 32)}
+	if c.family == netlink.FAMILY_V6 {
+		defaultDst = &net.IPNet{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)}
+	}
+
+	routes := []netlink.Route{
+		{
+			LinkIndex: link.Attrs().Index,
+			Dst:       defaultDst,
+			Gw:        c.gateway,
+			Flags:     int(netlink.FLAG_ONLINK),
+			Priority:  c.metric,
+			Table:     c.table,
+		},
+		{
+			LinkIndex: link.Attrs().Index,
+			Dst:       c.subnet,
+			Priority:  c.metric,
+			Scope:     netlink.SCOPE_LINK,
+			Table:     c.table,
+		},
+	}
+	for _, dst := range c.extraRoutes {
+		routes = append(routes, netlink.Route{
+			LinkIndex: link.Attrs().Index,
+			Dst:       dst,
+			Gw:        c.gateway,
+			Flags:     int(netlink.FLAG_ONLINK),
+			Priority:  c.metric,
+			Table:     c.table,
+		})
+	}
+
+	return routes
+}
+
+func findRule(c familyConfig, address *net.IPNet) (*netlink.Rule, error) {
+	rules, err := netlink.RuleList(c.family)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	for _, r := range rules {
+		if r.Table == c.table && r.Src != nil && r.Src.String() == address.String() {
+			rule := r
+			return &rule, nil
+		}
+	}
+	return nil, nil
+}
+
+func containsAddress(addresses []*net.IPNet, address *net.IPNet) bool {
+	for _, a := range addresses {
+		if a.String() == address.String() {
+			return true
+		}
+	}
+	return false
+}
+
+func containsRoute(routes []netlink.Route, desired netlink.Route) bool {
+	for _, r := range routes {
+		if destination(r) == destination(desired) && r.Gw.Equal(desired.Gw) {
+			return true
+		}
+	}
+	return false
+}
+
+func destination(r netlink.Route) string {
+	if r.Dst == nil {
+		// the kernel reports default routes without destination
+		if r.Gw != nil && r.Gw.To4() == nil {
+			return "::/0"
+		}
+		return "0.0.0.0/0"
+	}
+	return r.Dst.String()
+}
+
+func parseParams(p params) ([]familyConfig, error) {
+	var families []familyConfig
+
+	{
+		addresses, err := parseAddresses(p.ENIAddresses, false)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		gateway := net.ParseIP(p.ENIGateway)
+		if gateway == nil {
+			return nil, microerror.Maskf(executionFailedError, "invalid ENI gateway %q", p.ENIGateway)
+		}
+
+		_, subnet, err := net.ParseCIDR(p.ENISubnet)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		extraRoutes, err := parseRoutes(p.ExtraRoutes)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		families = append(families, familyConfig{
+			addresses:    addresses,
+			addressScope: addressScope,
+			extraRoutes:  extraRoutes,
+			family:       netlink.FAMILY_V4,
+			gateway:      gateway,
+			metric:       p.Metric,
+			rulePriority: p.RulePriority,
+			subnet:       subnet,
+			table:        p.Table,
+		})
+	}
+
+	if len(p.ENIIPv6Addresses) > 0 {
+		addresses, err := parseAddresses(p.ENIIPv6Addresses, true)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		gateway := net.ParseIP(p.ENIIPv6Gateway)
+		if gateway == nil || !gateway.IsLinkLocalUnicast() {
+			return nil, microerror.Maskf(executionFailedError, "invalid ENI IPv6 gateway %q, expected a link-local address", p.ENIIPv6Gateway)
+		}
+
+		_, subnet, err := net.ParseCIDR(p.ENIIPv6Subnet)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		extraRoutes, err := parseRoutes(p.ExtraIPv6Routes)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		families = append(families, familyConfig{
+			addresses:    addresses,
+			addressScope: int(netlink.SCOPE_UNIVERSE),
+			extraRoutes:  extraRoutes,
+			family:       netlink.FAMILY_V6,
+			gateway:      gateway,
+			metric:       p.Metric,
+			rulePriority: p.RulePriority,
+			subnet:       subnet,
+			table:        p.Table,
+		})
+	}
+
+	return families, nil
+}
+
+// parseAddresses parses addresses in CIDR notation, keeping the host part of
+// the address as it is assigned to the interface.
+func parseAddresses(cidrs []string, ipv6 bool) ([]*net.IPNet, error) {
+	var addresses []*net.IPNet
+	for _, c := range cidrs {
+		ip, ipNet, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		if (ip.To4() == nil) != ipv6 {
+			return nil, microerror.Maskf(executionFailedError, "invalid ENI address %q for address family", c)
+		}
+		if !ipv6 {
+			ip = ip.To4()
+		}
+		addresses = append(addresses, &net.IPNet{IP: ip, Mask: ipNet.Mask})
+	}
+	return addresses, nil
+}
+
+func parseRoutes(cidrs []string) ([]*net.IPNet, error) {
+	var routes []*net.IPNet
+	for _, c := range cidrs {
+		_, ipNet, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		routes = append(routes, ipNet)
+	}
+	return routes, nil
+}
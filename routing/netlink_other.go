@@ -0,0 +1,11 @@
+//go:build !linux
+
+package routing
+
+import (
+	"github.com/giantswarm/microerror"
+)
+
+func applyNetlink(p params) error {
+	return microerror.Maskf(executionFailedError, "routing backend %q is only supported on linux", BackendNetlink)
+}
@@ -1,25 +1,251 @@
 package routing
 
-const networkRoutingTemplate = `# ensure that traffic arriving on eth1 leaves again from eth1 to prevent asymetric routing
+const networkRoutingTemplate = `# ensure that traffic arriving on {{.InterfaceName}} leaves again from {{.InterfaceName}} to prevent asymetric routing
 [Match]
-Name=eth1
+MACAddress={{.MACAddress}}
+{{- range .ENIAddresses}}
 
 [Address]
-Address={{.ENIAddress}}/32
+Address={{.}}
 Scope=2
 
 [RoutingPolicyRule]
-Table=2
-From={{.ENIAddress}}/32
+Table={{$.Table}}
+From={{.}}
+{{- if $.RulePriority}}
+Priority={{$.RulePriority}}
+{{- end}}
+{{- end}}
 
 [Route]
 Destination=0.0.0.0/0
 Gateway={{.ENIGateway}}
 GatewayOnlink=true
-Table=2
+Table={{.Table}}
+{{- if .Metric}}
+Metric={{.Metric}}
+{{- end}}
 
 [Route]
 Destination={{.ENISubnet}}
-Table=2
+Table={{.Table}}
 Scope=link
+{{- if .Metric}}
+Metric={{.Metric}}
+{{- end}}
+{{- range .ExtraRoutes}}
+
+[Route]
+Destination={{.}}
+Gateway={{$.ENIGateway}}
+GatewayOnlink=true
+Table={{$.Table}}
+{{- if $.Metric}}
+Metric={{$.Metric}}
+{{- end}}
+{{- end}}
+{{- range .ENIIPv6Addresses}}
+
+[Address]
+Address={{.}}
+
+[RoutingPolicyRule]
+Table={{$.Table}}
+From={{.}}
+{{- if $.RulePriority}}
+Priority={{$.RulePriority}}
+{{- end}}
+{{- end}}
+{{- if .ENIIPv6Addresses}}
+
+[Route]
+Destination=::/0
+Gateway={{.ENIIPv6Gateway}}
+GatewayOnlink=true
+Table={{.Table}}
+{{- if .Metric}}
+Metric={{.Metric}}
+{{- end}}
+
+[Route]
+Destination={{.ENIIPv6Subnet}}
+Table={{.Table}}
+Scope=link
+{{- if .Metric}}
+Metric={{.Metric}}
+{{- end}}
+{{- range .ExtraIPv6Routes}}
+
+[Route]
+Destination={{.}}
+Gateway={{$.ENIIPv6Gateway}}
+GatewayOnlink=true
+Table={{$.Table}}
+{{- if $.Metric}}
+Metric={{$.Metric}}
+{{- end}}
+{{- end}}
+{{- end}}
+`
+
+const netplanRoutingTemplate = `# ensure that traffic arriving on {{.InterfaceName}} leaves again from {{.InterfaceName}} to prevent asymetric routing
+network:
+  version: 2
+  ethernets:
+    {{.InterfaceName}}:
+      match:
+        macaddress: {{.MACAddress}}
+      addresses:
+{{- range .ENIAddresses}}
+        - {{.}}
+{{- end}}
+{{- range .ENIIPv6Addresses}}
+        - {{.}}
+{{- end}}
+      routing-policy:
+{{- range .ENIAddresses}}
+        - from: {{.}}
+          table: {{$.Table}}
+{{- if $.RulePriority}}
+          priority: {{$.RulePriority}}
+{{- end}}
+{{- end}}
+{{- range .ENIIPv6Addresses}}
+        - from: {{.}}
+          table: {{$.Table}}
+{{- if $.RulePriority}}
+          priority: {{$.RulePriority}}
+{{- end}}
+{{- end}}
+      routes:
+        - to: 0.0.0.0/0
+          via: {{.ENIGateway}}
+          on-link: true
+          table: {{.Table}}
+{{- if .Metric}}
+          metric: {{.Metric}}
+{{- end}}
+        - to: {{.ENISubnet}}
+          scope: link
+          table: {{.Table}}
+{{- if .Metric}}
+          metric: {{.Metric}}
+{{- end}}
+{{- range .ExtraRoutes}}
+        - to: {{.}}
+          via: {{$.ENIGateway}}
+          on-link: true
+          table: {{$.Table}}
+{{- if $.Metric}}
+          metric: {{$.Metric}}
+{{- end}}
+{{- end}}
+{{- if .ENIIPv6Addresses}}
+        - to: ::/0
+          via: {{.ENIIPv6Gateway}}
+          on-link: true
+          table: {{.Table}}
+{{- if .Metric}}
+          metric: {{.Metric}}
+{{- end}}
+        - to: {{.ENIIPv6Subnet}}
+          scope: link
+          table: {{.Table}}
+{{- if .Metric}}
+          metric: {{.Metric}}
+{{- end}}
+{{- range .ExtraIPv6Routes}}
+        - to: {{.}}
+          via: {{$.ENIIPv6Gateway}}
+          on-link: true
+          table: {{$.Table}}
+{{- if $.Metric}}
+          metric: {{$.Metric}}
+{{- end}}
+{{- end}}
+{{- end}}
+`
+
+// NetworkManager requires a priority for routing rules, a rule priority must
+// be configured for this backend.
+const networkManagerRoutingTemplate = `# ensure that traffic arriving on {{.InterfaceName}} leaves again from {{.InterfaceName}} to prevent asymetric routing
+[connection]
+id={{.InterfaceName}}
+type=ethernet
+interface-name={{.InterfaceName}}
+
+[ipv4]
+method=manual
+{{- range $i, $a := .ENIAddresses}}
+address{{inc $i}}={{$a}}
+{{- end}}
+route1=0.0.0.0/0,{{.ENIGateway}}{{if .Metric}},{{.Metric}}{{end}}
+route1_options=onlink=true,table={{.Table}}
+route2={{.ENISubnet}}{{if .Metric}},0.0.0.0,{{.Metric}}{{end}}
+route2_options=table={{.Table}}
+{{- range $i, $r := .ExtraRoutes}}
+route{{add $i 3}}={{$r}},{{$.ENIGateway}}{{if $.Metric}},{{$.Metric}}{{end}}
+route{{add $i 3}}_options=onlink=true,table={{$.Table}}
+{{- end}}
+{{- range $i, $a := .ENIAddresses}}
+routing-rule{{inc $i}}=priority {{$.RulePriority}} from {{$a}} table {{$.Table}}
+{{- end}}
+
+[ipv6]
+{{- if .ENIIPv6Addresses}}
+method=manual
+{{- range $i, $a := .ENIIPv6Addresses}}
+address{{inc $i}}={{$a}}
+{{- end}}
+route1=::/0,{{.ENIIPv6Gateway}}{{if .Metric}},{{.Metric}}{{end}}
+route1_options=onlink=true,table={{.Table}}
+route2={{.ENIIPv6Subnet}}{{if .Metric}},::,{{.Metric}}{{end}}
+route2_options=table={{.Table}}
+{{- range $i, $r := .ExtraIPv6Routes}}
+route{{add $i 3}}={{$r}},{{$.ENIIPv6Gateway}}{{if $.Metric}},{{$.Metric}}{{end}}
+route{{add $i 3}}_options=onlink=true,table={{$.Table}}
+{{- end}}
+{{- range $i, $a := .ENIIPv6Addresses}}
+routing-rule{{inc $i}}=priority {{$.RulePriority}} from {{$a}} table {{$.Table}}
+{{- end}}
+{{- else}}
+method=ignore
+{{- end}}
+`
+
+const ifupdownRoutingTemplate = `# ensure that traffic arriving on {{.InterfaceName}} leaves again from {{.InterfaceName}} to prevent asymetric routing
+auto {{.InterfaceName}}
+iface {{.InterfaceName}} inet manual
+{{- range .ENIAddresses}}
+    up ip addr replace {{.}} dev {{$.InterfaceName}}
+{{- end}}
+{{- range .ENIAddresses}}
+    post-up ip rule add from {{.}} table {{$.Table}}{{if $.RulePriority}} priority {{$.RulePriority}}{{end}} || true
+{{- end}}
+    post-up ip route replace 0.0.0.0/0 via {{.ENIGateway}} dev {{.InterfaceName}} onlink table {{.Table}}{{if .Metric}} metric {{.Metric}}{{end}}
+    post-up ip route replace {{.ENISubnet}} dev {{.InterfaceName}} scope link table {{.Table}}{{if .Metric}} metric {{.Metric}}{{end}}
+{{- range .ExtraRoutes}}
+    post-up ip route replace {{.}} via {{$.ENIGateway}} dev {{$.InterfaceName}} onlink table {{$.Table}}{{if $.Metric}} metric {{$.Metric}}{{end}}
+{{- end}}
+{{- range .ENIAddresses}}
+    pre-down ip rule del from {{.}} table {{$.Table}} || true
+{{- end}}
+{{- if .ENIIPv6Addresses}}
+
+iface {{.InterfaceName}} inet6 manual
+{{- range .ENIIPv6Addresses}}
+    up ip -6 addr replace {{.}} dev {{$.InterfaceName}}
+{{- end}}
+{{- range .ENIIPv6Addresses}}
+    post-up ip -6 rule add from {{.}} table {{$.Table}}{{if $.RulePriority}} priority {{$.RulePriority}}{{end}} || true
+{{- end}}
+    post-up ip -6 route replace ::/0 via {{.ENIIPv6Gateway}} dev {{.InterfaceName}} onlink table {{.Table}}{{if .Metric}} metric {{.Metric}}{{end}}
+    post-up ip -6 route replace {{.ENIIPv6Subnet}} dev {{.InterfaceName}} table {{.Table}}{{if .Metric}} metric {{.Metric}}{{end}}
+{{- range .ExtraIPv6Routes}}
+    post-up ip -6 route replace {{.}} via {{$.ENIIPv6Gateway}} dev {{$.InterfaceName}} onlink table {{$.Table}}{{if $.Metric}} metric {{$.Metric}}{{end}}
+{{- end}}
+{{- range .ENIIPv6Addresses}}
+    pre-down ip -6 rule del from {{.}} table {{$.Table}} || true
+{{- end}}
+{{- end}}
 `
@@ -1,64 +1,198 @@
 package routing
 
 import (
-	"bytes"
-	"io/ioutil"
 	"net"
-	"text/template"
 
 	"github.com/giantswarm/microerror"
 )
 
 const (
-	eth1FileName = "/etc/systemd/network/10-eth1.network"
+	// DefaultIPv6Gateway is the link-local address of the VPC router.
+	DefaultIPv6Gateway = "fe80::1"
+	// DefaultTable is the routing table used for traffic originating from the
+	// ENI addresses.
+	DefaultTable = 2
+
+	// reserved kernel routing tables
+	tableDefault = 253
+	tableMain    = 254
+	tableLocal   = 255
 )
 
+type Config struct {
+	Backend string
+	// ENIAddress is the primary private IPv4 address of the ENI.
+	ENIAddress       string
+	ENIIPv4Prefixes  []string
+	ENIIPv6Addresses []string
+	ENIIPv6Prefixes  []string
+	ENIIPv6Subnet    *net.IPNet
+	// ENISecondaryAddresses are the secondary private IPv4 addresses of the
+	// ENI, e.g. used as VIP.
+	ENISecondaryAddresses []string
+	ENISubnet             *net.IPNet
+	// ExtraRoutes are additional destinations in CIDR notation routed via the
+	// ENI gateway in the ENI routing table.
+	ExtraRoutes   []string
+	InterfaceName string
+	IPv6Gateway   string
+	MACAddress    string
+	// Metric of the routes in the ENI routing table, 0 leaves it unset.
+	Metric int
+	// RulePriority of the routing policy rules, 0 leaves it to the kernel.
+	RulePriority int
+	Table        int
+	// Verify checks the interface, the route via the ENI gateway and the
+	// optional VerifyPeer after the configuration was applied.
+	Verify bool
+	// VerifyPeer is an optional host:port dialed from the ENI address.
+	VerifyPeer string
+}
+
 type params struct {
-	ENIAddress    string
-	ENIGateway    string
-	ENISubnet     string
-	ENISubnetSize int
+	ENIAddress string
+	// ENIAddresses holds the primary address followed by all secondary
+	// addresses and prefixes in CIDR notation.
+	ENIAddresses     []string
+	ENIGateway       string
+	ENISubnet        string
+	ENISubnetSize    int
+	ENIIPv6Addresses []string
+	ENIIPv6Gateway   string
+	ENIIPv6Subnet    string
+	ExtraIPv6Routes  []string
+	ExtraRoutes      []string
+	InterfaceName    string
+	MACAddress       string
+	Metric           int
+	RulePriority     int
+	Table            int
 }
 
-func ConfigureNetworkRoutingForENI(eniIP string, eniSubnet *net.IPNet) error {
-	p := params{
-		ENIAddress:    eniIP,
-		ENIGateway:    eniGateway(eniSubnet),
-		ENISubnet:     eniSubnet.String(),
-		ENISubnetSize: eniSubnetSize(eniSubnet),
+func ConfigureNetworkRoutingForENI(config Config) error {
+	if config.InterfaceName == "" {
+		return microerror.Maskf(invalidConfigError, "config.InterfaceName must not be empty")
+	}
+	if config.ENISubnet == nil || config.ENISubnet.IP.To4() == nil {
+		return microerror.Maskf(invalidConfigError, "config.ENISubnet must be an IPv4 subnet")
+	}
+	if config.Table <= 0 || config.Table == tableDefault || config.Table == tableMain || config.Table == tableLocal {
+		return microerror.Maskf(invalidConfigError, "config.Table must be a positive number other than the reserved main, local and default tables")
+	}
+	if config.RulePriority < 0 {
+		return microerror.Maskf(invalidConfigError, "config.RulePriority must not be negative")
+	}
+	if config.Metric < 0 {
+		return microerror.Maskf(invalidConfigError, "config.Metric must not be negative")
+	}
+	if config.VerifyPeer != "" {
+		if _, _, err := net.SplitHostPort(config.VerifyPeer); err != nil {
+			return microerror.Maskf(invalidConfigError, "config.VerifyPeer must be in host:port format")
+		}
 	}
 
-	err := renderRoutingNetworkdFile(p)
+	var extraRoutes, extraIPv6Routes []string
+	for _, r := range config.ExtraRoutes {
+		_, ipNet, err := net.ParseCIDR(r)
+		if err != nil {
+			return microerror.Maskf(invalidConfigError, "config.ExtraRoutes contains invalid CIDR %q", r)
+		}
+		if ipNet.IP.To4() != nil {
+			extraRoutes = append(extraRoutes, ipNet.String())
+		} else {
+			extraIPv6Routes = append(extraIPv6Routes, ipNet.String())
+		}
+	}
+
+	ipv6 := len(config.ENIIPv6Addresses) > 0 || len(config.ENIIPv6Prefixes) > 0
+	if ipv6 && config.ENIIPv6Subnet == nil {
+		return microerror.Maskf(invalidConfigError, "config.ENIIPv6Subnet must not be nil when IPv6 addresses are configured")
+	}
+	if !ipv6 && len(extraIPv6Routes) > 0 {
+		return microerror.Maskf(invalidConfigError, "config.ExtraRoutes contains IPv6 routes but the ENI has no IPv6 addresses")
+	}
+	if ipv6 && config.IPv6Gateway == "" {
+		return microerror.Maskf(invalidConfigError, "config.IPv6Gateway must not be empty when IPv6 addresses are configured")
+	}
+
+	var addresses []string
+	{
+		addresses = append(addresses, config.ENIAddress+"/32")
+		for _, a := range config.ENISecondaryAddresses {
+			addresses = append(addresses, a+"/32")
+		}
+		addresses = append(addresses, config.ENIIPv4Prefixes...)
+	}
+
+	var ipv6Addresses []string
+	{
+		for _, a := range config.ENIIPv6Addresses {
+			ipv6Addresses = append(ipv6Addresses, a+"/128")
+		}
+		ipv6Addresses = append(ipv6Addresses, config.ENIIPv6Prefixes...)
+	}
+
+	gateway, err := eniGateway(config.ENISubnet)
 	if err != nil {
 		return microerror.Mask(err)
 	}
 
-	return nil
-}
+	p := params{
+		ENIAddress:    config.ENIAddress,
+		ENIAddresses:  addresses,
+		ENIGateway:    gateway,
+		ENISubnet:     config.ENISubnet.String(),
+		ENISubnetSize: eniSubnetSize(config.ENISubnet),
+		ExtraRoutes:   extraRoutes,
+		InterfaceName: config.InterfaceName,
+		MACAddress:    config.MACAddress,
+		Metric:        config.Metric,
+		RulePriority:  config.RulePriority,
+		Table:         config.Table,
+	}
+	if ipv6 {
+		p.ExtraIPv6Routes = extraIPv6Routes
+		p.ENIIPv6Addresses = ipv6Addresses
+		p.ENIIPv6Gateway = config.IPv6Gateway
+		p.ENIIPv6Subnet = config.ENIIPv6Subnet.String()
+	}
 
-func renderRoutingNetworkdFile(p params) error {
-	var buff bytes.Buffer
-	t := template.Must(template.New("routing").Parse(networkRoutingTemplate))
+	b, err := newBackend(config.Backend, p)
+	if err != nil {
+		return microerror.Mask(err)
+	}
 
-	err := t.Execute(&buff, p)
+	err = detectConflicts(p)
 	if err != nil {
 		return microerror.Mask(err)
 	}
 
-	err = ioutil.WriteFile(eth1FileName, buff.Bytes(), 0644) // nolint
+	err = b.apply(p)
 	if err != nil {
 		return microerror.Mask(err)
 	}
 
+	if config.Verify {
+		err = verifyConnectivity(p, config.VerifyPeer)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
 	return nil
 }
 
-func eniGateway(ipNet *net.IPNet) string {
+func eniGateway(ipNet *net.IPNet) (string, error) {
 	// https://docs.aws.amazon.com/vpc/latest/userguide/VPC_Subnets.html
-	gatewayAddressIP := cloneIP(ipNet.IP)
+	// the IPv4 router is the network address plus one, IPv6 traffic is routed
+	// via the link-local address of the router instead
+	if ipNet == nil || ipNet.IP.To4() == nil {
+		return "", microerror.Maskf(invalidConfigError, "ENI gateway can only be derived from an IPv4 subnet, got %s", ipNet)
+	}
+	gatewayAddressIP := cloneIP(ipNet.IP.To4().Mask(ipNet.Mask))
 	gatewayAddressIP[3] += 1
 
-	return gatewayAddressIP.String()
+	return gatewayAddressIP.String(), nil
 }
 
 func eniSubnetSize(ipNet *net.IPNet) int {
@@ -0,0 +1,213 @@
+package routing
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func mustParseCIDR(t *testing.T, s string) *net.IPNet {
+	t.Helper()
+	ip, ipNet, err := net.ParseCIDR(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// keep the host bits to check they are masked
+	ipNet.IP = ip
+	return ipNet
+}
+
+func Test_eniGateway(t *testing.T) {
+	testCases := []struct {
+		name            string
+		subnet          string
+		expectedGateway string
+		expectedErr     func(error) bool
+	}{
+		{
+			name:            "case 0: /24 subnet",
+			subnet:          "10.0.1.0/24",
+			expectedGateway: "10.0.1.1",
+		},
+		{
+			name:            "case 1: subnet not starting at a /24 boundary",
+			subnet:          "10.0.1.64/26",
+			expectedGateway: "10.0.1.65",
+		},
+		{
+			name:            "case 2: address with host bits set",
+			subnet:          "172.16.3.17/20",
+			expectedGateway: "172.16.0.1",
+		},
+		{
+			name:        "case 3: IPv6 subnet is rejected",
+			subnet:      "2001:db8:1:2::/64",
+			expectedErr: IsInvalidConfig,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gateway, err := eniGateway(mustParseCIDR(t, tc.subnet))
+			if tc.expectedErr != nil {
+				if !tc.expectedErr(err) {
+					t.Fatalf("expected error, got %#v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %#v", err)
+			}
+			if gateway != tc.expectedGateway {
+				t.Fatalf("expected gateway %q, got %q", tc.expectedGateway, gateway)
+			}
+		})
+	}
+}
+
+func Test_ConfigureNetworkRoutingForENI_invalidConfig(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config Config
+	}{
+		{
+			name: "case 0: IPv6 ENI subnet",
+			config: Config{
+				Backend:       BackendNetworkd,
+				ENIAddress:    "2001:db8:1:2::10",
+				ENISubnet:     mustParseCIDR(t, "2001:db8:1:2::/64"),
+				InterfaceName: "eth1",
+				Table:         DefaultTable,
+			},
+		},
+		{
+			name: "case 1: IPv6 addresses without IPv6 subnet",
+			config: Config{
+				Backend:          BackendNetworkd,
+				ENIAddress:       "10.0.1.10",
+				ENIIPv6Addresses: []string{"2001:db8:1:2::10"},
+				ENISubnet:        mustParseCIDR(t, "10.0.1.0/24"),
+				IPv6Gateway:      DefaultIPv6Gateway,
+				InterfaceName:    "eth1",
+				Table:            DefaultTable,
+			},
+		},
+		{
+			name: "case 2: IPv6 extra routes without IPv6 addresses",
+			config: Config{
+				Backend:       BackendNetworkd,
+				ENIAddress:    "10.0.1.10",
+				ENISubnet:     mustParseCIDR(t, "10.0.1.0/24"),
+				ExtraRoutes:   []string{"10.1.0.0/16", "2001:db8:ff::/48"},
+				InterfaceName: "eth1",
+				Table:         DefaultTable,
+			},
+		},
+		{
+			name: "case 3: NetworkManager without rule priority",
+			config: Config{
+				Backend:       BackendNetworkManager,
+				ENIAddress:    "10.0.1.10",
+				ENISubnet:     mustParseCIDR(t, "10.0.1.0/24"),
+				InterfaceName: "eth1",
+				Table:         DefaultTable,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ConfigureNetworkRoutingForENI(tc.config)
+			if !IsInvalidConfig(err) {
+				t.Fatalf("expected invalid config error, got %#v", err)
+			}
+		})
+	}
+}
+
+func Test_fileBackend_render_IPv6(t *testing.T) {
+	p := params{
+		ENIAddress:       "10.0.1.10",
+		ENIAddresses:     []string{"10.0.1.10/32", "10.0.1.11/32"},
+		ENIGateway:       "10.0.1.1",
+		ENIIPv6Addresses: []string{"2001:db8:1:2::10/128", "2001:db8:1:2:1::/80"},
+		ENIIPv6Gateway:   DefaultIPv6Gateway,
+		ENIIPv6Subnet:    "2001:db8:1:2::/64",
+		ENISubnet:        "10.0.1.0/24",
+		ENISubnetSize:    24,
+		ExtraIPv6Routes:  []string{"2001:db8:ff::/48"},
+		InterfaceName:    "eth1",
+		MACAddress:       "02:00:00:00:00:01",
+		RulePriority:     1000,
+		Table:            DefaultTable,
+	}
+
+	testCases := []struct {
+		name     string
+		backend  string
+		expected []string
+	}{
+		{
+			name:    "case 0: networkd",
+			backend: BackendNetworkd,
+			expected: []string{
+				"Address=2001:db8:1:2::10/128\n",
+				"From=2001:db8:1:2:1::/80\nPriority=1000\n",
+				"Destination=::/0\nGateway=fe80::1\nGatewayOnlink=true\n",
+				"Destination=2001:db8:1:2::/64\n",
+				"Destination=2001:db8:ff::/48\nGateway=fe80::1\n",
+			},
+		},
+		{
+			name:    "case 1: netplan",
+			backend: BackendNetplan,
+			expected: []string{
+				"        - 2001:db8:1:2::10/128\n",
+				"        - from: 2001:db8:1:2:1::/80\n",
+				"        - to: ::/0\n          via: fe80::1\n",
+				"        - to: 2001:db8:1:2::/64\n",
+				"        - to: 2001:db8:ff::/48\n          via: fe80::1\n",
+			},
+		},
+		{
+			name:    "case 2: NetworkManager",
+			backend: BackendNetworkManager,
+			expected: []string{
+				"[ipv6]\nmethod=manual\naddress1=2001:db8:1:2::10/128\naddress2=2001:db8:1:2:1::/80\n",
+				"route1=::/0,fe80::1\nroute1_options=onlink=true,table=",
+				"route2=2001:db8:1:2::/64\nroute2_options=table=",
+				"route3=2001:db8:ff::/48,fe80::1\n",
+				"routing-rule2=priority 1000 from 2001:db8:1:2:1::/80 table ",
+			},
+		},
+		{
+			name:    "case 3: ifupdown",
+			backend: BackendIfupdown,
+			expected: []string{
+				"iface eth1 inet6 manual\n",
+				"    up ip -6 addr replace 2001:db8:1:2::10/128 dev eth1\n",
+				"    post-up ip -6 rule add from 2001:db8:1:2:1::/80 table ",
+				"    post-up ip -6 route replace ::/0 via fe80::1 dev eth1 onlink table ",
+				"    post-up ip -6 route replace 2001:db8:ff::/48 via fe80::1 dev eth1 onlink table ",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := newBackend(tc.backend, p)
+			if err != nil {
+				t.Fatalf("unexpected error %#v", err)
+			}
+			content, err := b.(fileBackend).render(p)
+			if err != nil {
+				t.Fatalf("unexpected error %#v", err)
+			}
+			for _, e := range tc.expected {
+				if !strings.Contains(string(content), e) {
+					t.Errorf("expected %q in rendered configuration:\n%s", e, content)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package routing
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/giantswarm/backoff"
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	// file based backends are applied asynchronously by the network
+	// configuration tool of the host, so wait up to 2 mins
+	verifyMaxRetries    = 60
+	verifyRetryInterval = time.Second * 2
+	verifyDialTimeout   = time.Second * 5
+)
+
+// verifyConnectivity checks that the configuration took effect: the
+// interface is up with the ENI address, traffic from the ENI address is
+// routed via the ENI routing table to the ENI gateway and, if configured, the
+// peer is reachable from the ENI address.
+func verifyConnectivity(p params, peer string) error {
+	b := backoff.NewMaxRetries(verifyMaxRetries, verifyRetryInterval)
+	o := func() error {
+		err := verifyInterface(p)
+		if err != nil {
+			fmt.Printf("Verifying interface %s failed, retrying in %ds, err: %s.\n", p.InterfaceName, verifyRetryInterval/time.Second, err)
+			return microerror.Mask(err)
+		}
+
+		err = verifyRoute(p)
+		if err != nil {
+			fmt.Printf("Verifying route from %s failed, retrying in %ds, err: %s.\n", p.ENIAddress, verifyRetryInterval/time.Second, err)
+			return microerror.Mask(err)
+		}
+
+		if peer != "" {
+			err = verifyPeer(p.ENIAddress, peer)
+			if err != nil {
+				fmt.Printf("Verifying peer %s failed, retrying in %ds, err: %s.\n", peer, verifyRetryInterval/time.Second, err)
+				return microerror.Mask(err)
+			}
+		}
+
+		return nil
+	}
+	err := backoff.Retry(o, b)
+	if err != nil {
+		fmt.Printf("Failed to verify connectivity via %s after %d retries.\n", p.InterfaceName, verifyMaxRetries)
+		return microerror.Mask(err)
+	}
+
+	fmt.Printf("Verified connectivity via %s.\n", p.InterfaceName)
+	return nil
+}
+
+// verifyPeer opens a TCP connection to the peer using the ENI address as
+// source.
+func verifyPeer(source string, peer string) error {
+	d := net.Dialer{
+		LocalAddr: &net.TCPAddr{IP: net.ParseIP(source)},
+		Timeout:   verifyDialTimeout,
+	}
+
+	conn, err := d.Dial("tcp", peer)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	defer conn.Close()
+
+	fmt.Printf("Peer %s is reachable from %s.\n", peer, source)
+	return nil
+}
@@ -0,0 +1,76 @@
+//go:build linux
+
+package routing
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/giantswarm/microerror"
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	// verifyProbeAddress is an address outside of any VPC (TEST-NET-1) used to
+	// look up the route taken by traffic leaving the ENI subnet, no packets
+	// are sent to it.
+	verifyProbeAddress = "192.0.2.1"
+)
+
+// verifyInterface checks that the interface is up and has the primary ENI
+// address assigned.
+func verifyInterface(p params) error {
+	link, err := netlink.LinkByName(p.InterfaceName)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if link.Attrs().Flags&net.FlagUp == 0 {
+		return microerror.Maskf(executionFailedError, "interface %s is not up", p.InterfaceName)
+	}
+
+	addrs, err := netlink.AddrList(link, netlink.FAMILY_V4)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	for _, a := range addrs {
+		if a.IP.Equal(net.ParseIP(p.ENIAddress)) {
+			return nil
+		}
+	}
+
+	return microerror.Maskf(executionFailedError, "address %s is not configured on %s", p.ENIAddress, p.InterfaceName)
+}
+
+// verifyRoute checks the equivalent of ip route get <probe> from <eni
+// address>, the lookup must resolve via the ENI routing table to the ENI
+// gateway on the ENI interface.
+func verifyRoute(p params) error {
+	link, err := netlink.LinkByName(p.InterfaceName)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	routes, err := netlink.RouteGetWithOptions(net.ParseIP(verifyProbeAddress), &netlink.RouteGetOptions{
+		SrcAddr: net.ParseIP(p.ENIAddress),
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if len(routes) == 0 {
+		return microerror.Maskf(executionFailedError, "no route to %s from %s", verifyProbeAddress, p.ENIAddress)
+	}
+
+	r := routes[0]
+	if r.Table != p.Table {
+		return microerror.Maskf(executionFailedError, "route to %s from %s resolves via table %d, expected table %d", verifyProbeAddress, p.ENIAddress, r.Table, p.Table)
+	}
+	if !r.Gw.Equal(net.ParseIP(p.ENIGateway)) {
+		return microerror.Maskf(executionFailedError, "route to %s from %s resolves via gateway %s, expected gateway %s", verifyProbeAddress, p.ENIAddress, r.Gw, p.ENIGateway)
+	}
+	if r.LinkIndex != link.Attrs().Index {
+		return microerror.Maskf(executionFailedError, "route to %s from %s does not leave via %s", verifyProbeAddress, p.ENIAddress, p.InterfaceName)
+	}
+
+	fmt.Printf("Route to %s from %s resolves via table %d and gateway %s on %s.\n", verifyProbeAddress, p.ENIAddress, r.Table, r.Gw, p.InterfaceName)
+	return nil
+}
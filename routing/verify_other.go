@@ -0,0 +1,17 @@
+//go:build !linux
+
+package routing
+
+import (
+	"fmt"
+)
+
+func verifyInterface(p params) error {
+	fmt.Printf("Verifying the interface is only supported on linux, skipping.\n")
+	return nil
+}
+
+func verifyRoute(p params) error {
+	fmt.Printf("Verifying the route is only supported on linux, skipping.\n")
+	return nil
+}
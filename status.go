@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/aws-attach-etcd-dep/aws"
+)
+
+func printStatus(eni *aws.ENI, ebs *aws.EBS) error {
+	eniStatus, err := eni.Status()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	fmt.Printf("ENI %s\n", eniStatus.ID)
+	fmt.Printf("  state:             %s\n", eniStatus.State)
+	fmt.Printf("  attached instance: %s\n", eniStatus.AttachedInstance)
+	fmt.Printf("  private ip:        %s\n", eniStatus.PrivateIP)
+	fmt.Printf("  security groups:   %s\n", eniStatus.SecurityGroups)
+	fmt.Printf("  source/dest check: %t\n", eniStatus.SourceDestCheck)
+	if len(eniStatus.Drift) == 0 {
+		fmt.Printf("  drift:             none\n")
+	}
+	for _, d := range eniStatus.Drift {
+		fmt.Printf("  drift:             %s\n", d)
+	}
+	printHistory(eniStatus.History)
+
+	ebsStatus, err := ebs.Status()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	fmt.Printf("Volume %s\n", ebsStatus.ID)
+	fmt.Printf("  state:              %s\n", ebsStatus.State)
+	fmt.Printf("  availability zone:  %s\n", ebsStatus.AvailabilityZone)
+	fmt.Printf("  attached instances: %s\n", ebsStatus.AttachedInstances)
+	printHistory(ebsStatus.History)
+
+	return nil
+}
+
+func printHistory(h aws.AttachmentHistory) {
+	if h.LastAttachedInstance == "" {
+		fmt.Printf("  history:           none\n")
+		return
+	}
+	fmt.Printf("  last attached:     %s at %s, tool version %s\n", h.LastAttachedInstance, h.LastAttachedAt, h.ToolVersion)
+	fmt.Printf("  last detach mode:  %s\n", h.LastDetachMode)
+	for _, e := range h.Entries {
+		fmt.Printf("  history:           %s\n", e)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/aws-attach-etcd-dep/aws"
+	"github.com/giantswarm/aws-attach-etcd-dep/metadata"
+)
+
+// tagTemplateData is available in templated tag and filter values, e.g.
+// {{ .InstanceTags.etcd_member }} or {{ index .InstanceTags "etcd-member" }}.
+type tagTemplateData struct {
+	InstanceID   string
+	InstanceTags map[string]string
+}
+
+// renderTagTemplates renders the tag and filter values used to find the ENI
+// and the volume, so one launch template can serve all etcd members. The
+// instance tags are only fetched if any value is a template.
+func renderTagTemplates(f *Flag, awsSession *session.Session, instanceID string) error {
+	values := []*string{&f.EniTagValue, &f.VolumeTagValue}
+	for i := range f.EniFilters {
+		values = append(values, &f.EniFilters[i])
+	}
+	for i := range f.VolumeFilters {
+		values = append(values, &f.VolumeFilters[i])
+	}
+
+	var templated []*string
+	for _, v := range values {
+		if strings.Contains(*v, "{{") {
+			templated = append(templated, v)
+		}
+	}
+	if len(templated) == 0 {
+		return nil
+	}
+
+	tags, err := getInstanceTags(awsSession, instanceID)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	data := tagTemplateData{
+		InstanceID:   instanceID,
+		InstanceTags: tags,
+	}
+	for _, v := range templated {
+		t, err := template.New("tag").Option("missingkey=error").Parse(*v)
+		if err != nil {
+			return microerror.Maskf(invalidFlagError, "failed to parse template %q, err: %s", *v, err)
+		}
+
+		var buff bytes.Buffer
+		err = t.Execute(&buff, data)
+		if err != nil {
+			return microerror.Maskf(invalidFlagError, "failed to render template %q, err: %s", *v, err)
+		}
+
+		fmt.Printf("Rendered %q to %q.\n", *v, buff.String())
+		*v = buff.String()
+	}
+
+	return nil
+}
+
+func getInstanceTags(awsSession *session.Session, instanceID string) (map[string]string, error) {
+	tags, err := metadata.GetInstanceTags(awsSession)
+	if err == nil {
+		return tags, nil
+	}
+	fmt.Printf("Failed to get instance tags from instance metadata, falling back to DescribeTags, err: %s.\n", err)
+
+	tags, err = aws.GetInstanceTags(awsSession, instanceID)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	return tags, nil
+}